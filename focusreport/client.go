@@ -0,0 +1,197 @@
+package focusreport
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// headCacheClient keeps the HeadObject responses of a run, so the skip
+// check, -date-source metadata, -object-list and the summary fetch the
+// metadata of an object at most once. Concurrent calls for the same object
+// wait for the first one; failed calls are not kept.
+type headCacheClient struct {
+	ObjectStorageAPI
+	mu    sync.Mutex
+	heads map[string]*headEntry
+}
+
+type headEntry struct {
+	done chan struct{} // closed once resp and err are set
+	resp objectstorage.HeadObjectResponse
+	err  error
+}
+
+func newHeadCacheClient(client ObjectStorageAPI) *headCacheClient {
+	return &headCacheClient{ObjectStorageAPI: client, heads: make(map[string]*headEntry)}
+}
+
+func (c *headCacheClient) HeadObject(ctx context.Context, req objectstorage.HeadObjectRequest) (objectstorage.HeadObjectResponse, error) {
+	key := stringValue(req.NamespaceName) + "/" + stringValue(req.BucketName) + "/" + stringValue(req.ObjectName)
+	c.mu.Lock()
+	entry, ok := c.heads[key]
+	if !ok {
+		entry = &headEntry{done: make(chan struct{})}
+		c.heads[key] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.resp, entry.err
+		case <-ctx.Done():
+			return objectstorage.HeadObjectResponse{}, ctx.Err()
+		}
+	}
+
+	entry.resp, entry.err = c.ObjectStorageAPI.HeadObject(ctx, req)
+	if entry.err != nil {
+		c.mu.Lock()
+		delete(c.heads, key)
+		c.mu.Unlock()
+	}
+	close(entry.done)
+	return entry.resp, entry.err
+}
+
+// getObjectSize gets the actual size of an object by fetching its metadata
+func getObjectSize(ctx context.Context, client ObjectStorageAPI, retry RetryConfig, namespace, bucketName, objectName string) (int64, error) {
+	resp, err := getObjectMetadata(ctx, client, retry, namespace, bucketName, objectName)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.ContentLength == nil {
+		return 0, fmt.Errorf("content length not available for %s", objectName)
+	}
+
+	return *resp.ContentLength, nil
+}
+
+// getObjectMetadata fetches an object's metadata with HeadObject
+func getObjectMetadata(ctx context.Context, client ObjectStorageAPI, retry RetryConfig, namespace, bucketName, objectName string) (objectstorage.HeadObjectResponse, error) {
+	req := objectstorage.HeadObjectRequest{
+		NamespaceName: &namespace,
+		BucketName:    &bucketName,
+		ObjectName:    &objectName,
+	}
+
+	var resp objectstorage.HeadObjectResponse
+	_, err := withRetry(ctx, retry, "HeadObject "+objectName, func() error {
+		return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+			var err error
+			resp, err = client.HeadObject(ctx, req)
+			return withRetryAfter(err, resp.RawResponse)
+		})
+	})
+	if err != nil {
+		return resp, fmt.Errorf("failed to get object metadata for %s: %w", objectName, err)
+	}
+	return resp, nil
+}
+
+// ResolveNamespace looks up the Object Storage namespace of the caller's tenancy
+func ResolveNamespace(ctx context.Context, client objectstorage.ObjectStorageClient, retry RetryConfig) (string, error) {
+	var resp objectstorage.GetNamespaceResponse
+	_, err := withRetry(ctx, retry, "GetNamespace", func() error {
+		return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+			var err error
+			resp, err = client.GetNamespace(ctx, objectstorage.GetNamespaceRequest{})
+			return withRetryAfter(err, resp.RawResponse)
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Value == nil || *resp.Value == "" {
+		return "", fmt.Errorf("empty namespace returned")
+	}
+	return *resp.Value, nil
+}
+
+// HeadBucket checks that the bucket exists and is readable
+func HeadBucket(ctx context.Context, client objectstorage.ObjectStorageClient, retry RetryConfig, namespace, bucketName string) error {
+	req := objectstorage.HeadBucketRequest{
+		NamespaceName: &namespace,
+		BucketName:    &bucketName,
+	}
+	_, err := withRetry(ctx, retry, "HeadBucket "+bucketName, func() error {
+		return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+			resp, err := client.HeadBucket(ctx, req)
+			return withRetryAfter(err, resp.RawResponse)
+		})
+	})
+	return err
+}
+
+// UploadFile copies the local file filename to objectName in bucketName.
+// The request carries the file's MD5 so Object Storage rejects a corrupted
+// upload, and the stored object must then report the ETag PutObject returned.
+func UploadFile(ctx context.Context, client objectstorage.ObjectStorageClient, retry RetryConfig, namespace, bucketName, objectName, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	contentMD5 := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	size := info.Size()
+
+	var etag string
+	_, err = withRetry(ctx, retry, "PutObject "+objectName, func() error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+			resp, err := client.PutObject(ctx, objectstorage.PutObjectRequest{
+				NamespaceName: &namespace,
+				BucketName:    &bucketName,
+				ObjectName:    &objectName,
+				ContentLength: &size,
+				ContentMD5:    &contentMD5,
+				PutObjectBody: io.NopCloser(f),
+			})
+			if err == nil && resp.ETag != nil {
+				etag = *resp.ETag
+			}
+			return withRetryAfter(err, resp.RawResponse)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var head objectstorage.HeadObjectResponse
+	_, err = withRetry(ctx, retry, "HeadObject "+objectName, func() error {
+		return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+			var err error
+			head, err = client.HeadObject(ctx, objectstorage.HeadObjectRequest{
+				NamespaceName: &namespace,
+				BucketName:    &bucketName,
+				ObjectName:    &objectName,
+			})
+			return withRetryAfter(err, head.RawResponse)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("verifying upload: %w", err)
+	}
+	if head.ETag == nil || *head.ETag != etag {
+		return fmt.Errorf("uploaded object does not have the ETag %q returned by PutObject", etag)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package focusreport
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+func TestHeadCacheClient(t *testing.T) {
+	fake := newFakeClient(map[string]string{"a.csv": "a", "b.csv": "b"})
+	client := newHeadCacheClient(fake)
+	head := func(name string) error {
+		_, err := client.HeadObject(context.Background(), objectstorage.HeadObjectRequest{
+			NamespaceName: common.String("ns"),
+			BucketName:    common.String("bucket"),
+			ObjectName:    common.String(name),
+		})
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := head("a.csv"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := head("b.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.heads.Load(); n != 2 {
+		t.Errorf("made %d HEADs for two objects, want 2", n)
+	}
+
+	// Failures are not kept, so a later call asks again
+	for i := 0; i < 2; i++ {
+		if err := head("missing.csv"); !isNotFound(err) {
+			t.Fatalf("HEAD of a missing object returned %v, want a 404", err)
+		}
+	}
+	if n := fake.heads.Load(); n != 4 {
+		t.Errorf("made %d HEADs, want 4 with the two failed ones", n)
+	}
+}
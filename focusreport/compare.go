@@ -0,0 +1,83 @@
+package focusreport
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// compareResult is a row of the -compare-only report
+type compareResult struct {
+	ObjectName   string
+	RelativePath string
+	ReportDate   string
+	RemoteSize   int64
+	LocalSize    int64  // -1 when the file is missing
+	Status       string // present, missing or size_mismatch
+}
+
+// compareLocal checks each report against its file under
+// config.DownloadFolder. Sizes are only compared for files stored as-is:
+// decompressed files and reports with an unknown size count as present.
+func compareLocal(reports []Report, config Config) []compareResult {
+	var results []compareResult
+	for _, r := range reports {
+		relPath, date, decompress := targetPath(r.Name, config)
+		basePath := filepath.Join(config.DownloadFolder, filepath.FromSlash(relPath))
+		filePath := localPath(basePath, config.InferExtension)
+		relPath += strings.TrimPrefix(filePath, basePath)
+		result := compareResult{
+			ObjectName:   r.Name,
+			RelativePath: relPath,
+			ReportDate:   date,
+			RemoteSize:   r.Size,
+			LocalSize:    -1,
+			Status:       "missing",
+		}
+		if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
+			result.LocalSize = info.Size()
+			result.Status = "present"
+			if !decompress && !r.SizeUnknown && info.Size() != r.Size {
+				result.Status = "size_mismatch"
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// writeComparisonCSV writes the -compare-only report
+func writeComparisonCSV(results []compareResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"object_name", "relative_path", "report_date", "remote_size", "local_size", "comparison_status"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		localSize := ""
+		if r.LocalSize >= 0 {
+			localSize = strconv.FormatInt(r.LocalSize, 10)
+		}
+		record := []string{
+			r.ObjectName,
+			r.RelativePath,
+			r.ReportDate,
+			strconv.FormatInt(r.RemoteSize, 10),
+			localSize,
+			r.Status,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,126 @@
+package focusreport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sumBilledCost streams the FOCUS CSV at filePath and adds its BilledCost
+// values to totals, keyed by BillingCurrency. Rows with an empty BilledCost
+// are ignored.
+func sumBilledCost(filePath string, totals map[string]float64) error {
+	r, err := openReportFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cr := csv.NewReader(r)
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	costCol, currencyCol := -1, -1
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		switch strings.TrimSpace(name) {
+		case "BilledCost":
+			costCol = i
+		case "BillingCurrency":
+			currencyCol = i
+		}
+	}
+	if costCol < 0 || currencyCol < 0 {
+		return errors.New("missing BilledCost or BillingCurrency column")
+	}
+
+	// Sum per file first so a parse error leaves totals untouched
+	fileTotals := make(map[string]float64)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if costCol >= len(record) || currencyCol >= len(record) {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: too few fields", line)
+		}
+		value := strings.TrimSpace(record[costCol])
+		if value == "" {
+			continue
+		}
+		cost, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			line, _ := cr.FieldPos(costCol)
+			return fmt.Errorf("line %d: invalid BilledCost %q", line, value)
+		}
+		fileTotals[strings.TrimSpace(record[currencyCol])] += cost
+	}
+	for currency, cost := range fileTotals {
+		totals[currency] += cost
+	}
+	return nil
+}
+
+// summarizeCost sums BilledCost by BillingCurrency across the local files of
+// results that are on disk. A file that fails to parse is logged and left
+// out; the number of such files is returned.
+func summarizeCost(results []OperationResult, downloadFolder string) (map[string]float64, int) {
+	totals := make(map[string]float64)
+	failed := 0
+	for _, r := range results {
+		if !r.Downloaded && r.Status != "Already exists" {
+			continue
+		}
+		filePath := filepath.Join(downloadFolder, filepath.FromSlash(r.RelativePath))
+		if err := sumBilledCost(filePath, totals); err != nil {
+			slog.Warn("Could not summarize cost", "path", filePath, "error", err)
+			failed++
+		}
+	}
+	return totals, failed
+}
+
+// writeCostSummary writes the per-currency BilledCost totals as CSV, sorted by currency
+func writeCostSummary(totals map[string]float64, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"currency", "total_billed_cost"}); err != nil {
+		return err
+	}
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		if err := writer.Write([]string{currency, strconv.FormatFloat(totals[currency], 'f', 6, 64)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,182 @@
+package focusreport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseDateFromName extracts the report date from an object name by scanning its
+// directory segments for a YYYY/MM/DD triple, preferring the one nearest the file
+func parseDateFromName(name string) (time.Time, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 4 {
+		return time.Time{}, fmt.Errorf("invalid object name format: %s", name)
+	}
+	// The last segment is the file name itself, so only directories are candidates
+	for i := len(parts) - 4; i >= 0; i-- {
+		if date, ok := parseDateSegments(parts[i], parts[i+1], parts[i+2]); ok {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no YYYY/MM/DD date found in object name: %s", name)
+}
+
+// parseDateSegments parses year, month and day path segments into a UTC date.
+// Month and day may be zero-padded or not. Dates that do not exist, such as
+// 2023/02/29 or 2024/04/31, are rejected rather than normalized by time.Date.
+func parseDateSegments(y, m, d string) (time.Time, bool) {
+	if len(y) != 4 || !isDigits(y) || len(m) > 2 || !isDigits(m) || len(d) > 2 || !isDigits(d) {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(y)
+	month, _ := strconv.Atoi(m)
+	day, _ := strconv.Atoi(d)
+	if month < 1 || month > 12 || day < 1 {
+		return time.Time{}, false
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// isDigits reports whether s is non-empty and made of ASCII digits only
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// DateIndex holds report dates read from object metadata for -date-source
+// metadata. It is filled while listing and read when naming and reporting.
+type DateIndex struct {
+	mu    sync.Mutex
+	key   string // OpcMeta key, without the opc-meta- prefix
+	dates map[string]time.Time
+}
+
+// NewDateIndex returns an empty index of the dates in the OpcMeta key
+func NewDateIndex(key string) *DateIndex {
+	return &DateIndex{key: strings.ToLower(strings.TrimPrefix(key, "opc-meta-")), dates: make(map[string]time.Time)}
+}
+
+// Get returns the metadata date of objectName, if one was found
+func (d *DateIndex) Get(objectName string) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	date, ok := d.dates[objectName]
+	return date, ok
+}
+
+// Resolve reads the metadata date of each object with HeadObject, at most
+// workers calls in flight. Objects without a usable date are left out and
+// fall back to their path.
+func (d *DateIndex) Resolve(ctx context.Context, client ObjectStorageAPI, retry RetryConfig, workers int, namespace, bucketName string, names []string) {
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			meta, err := getObjectMetadata(ctx, client, retry, namespace, bucketName, name)
+			if err != nil {
+				slog.Warn("Could not read object metadata, using the path date", "object", name, "error", err)
+				return
+			}
+			value, ok := meta.OpcMeta[d.key]
+			if !ok {
+				return
+			}
+			date, err := parseMetadataDate(value)
+			if err != nil {
+				slog.Warn("Invalid report date in object metadata, using the path date", "object", name, "key", d.key, "value", value)
+				return
+			}
+			d.mu.Lock()
+			d.dates[name] = date
+			d.mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+}
+
+// parseMetadataDate parses a report date stored in object metadata as
+// YYYY-MM-DD, YYYY/MM/DD, YYYYMMDD or RFC3339
+func parseMetadataDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "20060102"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognized date %q", value)
+	}
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// reportDate returns the report date of an object and where it came from:
+// "metadata" when -date-source metadata found one, otherwise "path"
+func reportDate(objectName string, config Config) (time.Time, string, error) {
+	if config.Dates != nil {
+		if date, ok := config.Dates.Get(objectName); ok {
+			return date, "metadata", nil
+		}
+	}
+	date, err := parseDateFromName(objectName)
+	return date, "path", err
+}
+
+// DaysCutoff returns the earliest report date included by -days: UTC midnight
+// of the (days-1)th day before now, so -days 7 covers today and the 6 days
+// before it. Report dates are UTC days, so the cutoff must be too; computing
+// it from local time shifts the window by a day near midnight.
+func DaysCutoff(now time.Time, days int) time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, 1-days)
+}
+
+// dateInRange reports whether date falls within [start, end]; a zero bound is
+// open. All report dates and bounds are UTC, compared as instants.
+func dateInRange(date, start, end time.Time) bool {
+	if !start.IsZero() && date.Before(start) {
+		return false
+	}
+	if !end.IsZero() && date.After(end) {
+		return false
+	}
+	return true
+}
+
+// formatDateForFilename formats date as YYYYMMDD for filename prefix
+func formatDateForFilename(date time.Time) string {
+	return date.Format("20060102")
+}
+
+// parseFilenameDate is the inverse of formatDateForFilename: it returns the
+// date of a YYYYMMDD_ prefixed file name
+func parseFilenameDate(name string) (time.Time, bool) {
+	if len(name) <= 9 || name[8] != '_' || !isDigits(name[:8]) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse("20060102", name[:8])
+	return date, err == nil
+}
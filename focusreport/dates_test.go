@@ -0,0 +1,157 @@
+package focusreport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateFromNameLayouts(t *testing.T) {
+	want := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz",
+		"exports/FOCUS/2024/03/15/part-0.csv",
+		"tenancy/exports/FOCUS/2024/03/15/hourly/part-0.csv",
+		"FOCUS_REPORT/2024/03/15/ocid1.tenancy.oc1..aaaa/0001.csv.gz",
+	} {
+		got, err := parseDateFromName(name)
+		if err != nil {
+			t.Errorf("parseDateFromName(%q): %v", name, err)
+		} else if !got.Equal(want) {
+			t.Errorf("parseDateFromName(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	for _, name := range []string{"0001.csv.gz", "FOCUS/2024/03/15", "FOCUS Reports/latest/today/0001.csv.gz"} {
+		if _, err := parseDateFromName(name); err == nil {
+			t.Errorf("parseDateFromName(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestParseDateFromName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want string // 2006-01-02, empty when the name has no valid date
+	}{
+		{"FOCUS Reports/2024/03/15/0001.csv.gz", "2024-03-15"},
+		{"FOCUS Reports/2024/02/29/0001.csv.gz", "2024-02-29"},
+		{"FOCUS Reports/2023/02/29/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/04/31/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/3/5/0001.csv.gz", "2024-03-05"},
+		{"FOCUS Reports/2024/13/01/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/00/01/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/03/00/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/003/01/0001.csv.gz", ""},
+		{"archive/2023/12/31/FOCUS Reports/2024/03/15/0001.csv.gz", "2024-03-15"},
+		{"FOCUS Reports/2024/03/0001.csv.gz", ""},
+		{"FOCUS Reports/2024/03/15.csv.gz", ""},
+	} {
+		date, err := parseDateFromName(tc.name)
+		got := ""
+		if err == nil {
+			got = date.Format("2006-01-02")
+			if date.Location() != time.UTC {
+				t.Errorf("parseDateFromName(%q) is in %v, want UTC", tc.name, date.Location())
+			}
+		}
+		if got != tc.want {
+			t.Errorf("parseDateFromName(%q) = %q (%v), want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseDateSegments(t *testing.T) {
+	for _, tc := range []struct {
+		y, m, d string
+		ok      bool
+	}{
+		{"2024", "02", "29", true},
+		{"2023", "02", "29", false},
+		{"2024", "1", "9", true},
+		{"2024", "13", "01", false},
+		{"24", "01", "01", false},
+		{"2024", "1a", "01", false},
+		{"2024", "01", "", false},
+	} {
+		if _, ok := parseDateSegments(tc.y, tc.m, tc.d); ok != tc.ok {
+			t.Errorf("parseDateSegments(%q, %q, %q) ok = %v, want %v", tc.y, tc.m, tc.d, ok, tc.ok)
+		}
+	}
+}
+
+func TestAgeBoundaries(t *testing.T) {
+	// main bounds the dates to [now - max-age, now - min-age]
+	now := time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC)
+	day15 := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	day16 := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name           string
+		maxAge, minAge time.Duration
+		date           time.Time
+		want           bool
+	}{
+		{"exactly max-age old", 36 * time.Hour, 0, day15, true},
+		{"a second older than max-age", 36*time.Hour - time.Second, 0, day15, false},
+		{"exactly min-age old", 0, 12 * time.Hour, day16, true},
+		{"a second younger than min-age", 0, 12*time.Hour + time.Second, day16, false},
+		{"within both", 36 * time.Hour, 12 * time.Hour, day15, true},
+	} {
+		var from, to time.Time
+		if tc.maxAge > 0 {
+			from = now.Add(-tc.maxAge)
+		}
+		if tc.minAge > 0 {
+			to = now.Add(-tc.minAge)
+		}
+		if got := dateInRange(tc.date, from, to); got != tc.want {
+			t.Errorf("%s: dateInRange(%v, %v, %v) = %v, want %v", tc.name, tc.date, from, to, got, tc.want)
+		}
+	}
+}
+
+func TestDaysCutoffIsUTC(t *testing.T) {
+	// Far enough east that local time is already the next day
+	local := time.Local
+	time.Local = time.FixedZone("UTC+14", 14*60*60)
+	defer func() { time.Local = local }()
+
+	now := time.Date(2024, 3, 15, 23, 30, 0, 0, time.UTC).In(time.Local)
+	if got, want := DaysCutoff(now, 1), time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("DaysCutoff(%v, 1) = %v, want %v", now, got, want)
+	}
+	if got, want := DaysCutoff(now, 7), time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("DaysCutoff(%v, 7) = %v, want %v", now, got, want)
+	}
+
+	date, err := parseDateFromName("FOCUS Reports/2024/03/15/0001.csv.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dateInRange(date, DaysCutoff(now, 1), time.Time{}) {
+		t.Errorf("today's report %v is outside the -days 1 window", date)
+	}
+	if dateInRange(date.AddDate(0, 0, -1), DaysCutoff(now, 1), time.Time{}) {
+		t.Errorf("yesterday's report is inside the -days 1 window")
+	}
+}
+
+func TestParseFilenameDate(t *testing.T) {
+	date := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	if got, ok := parseFilenameDate(formatDateForFilename(date) + "_0001.csv.gz"); !ok || !got.Equal(date) {
+		t.Errorf("parseFilenameDate did not invert formatDateForFilename: got %v, %v", got, ok)
+	}
+	for _, name := range []string{
+		"20240315_",
+		"20240315",
+		"20240315-0001.csv",
+		"2024031_0001.csv",
+		"2024-03-15_0001.csv",
+		"20230229_0001.csv",
+		"20241301_0001.csv",
+		"0001.csv.gz",
+	} {
+		if got, ok := parseFilenameDate(name); ok {
+			t.Errorf("parseFilenameDate(%q) = %v, want no date", name, got)
+		}
+	}
+}
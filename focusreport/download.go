@@ -0,0 +1,656 @@
+package focusreport
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// fetchResult describes a completed GetObject transfer
+type fetchResult struct {
+	ETag          string
+	ContentLength int64 // size announced by GetObject
+	Received      int64 // bytes of the object on disk, including a resumed partial
+	Written       int64 // bytes written to disk
+	ResumedFrom   int64 // offset a partial download was resumed from, 0 if fresh
+	CopyDuration  time.Duration
+	ContentType   string
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// fetchOptions controls how fetchObject transfers an object
+type fetchOptions struct {
+	Verify         bool
+	Decompress     bool
+	Progress       *progressTracker // may be nil
+	RequestTimeout time.Duration    // maximum stall without data, 0 for none
+	Resume         bool             // keep partials on failure and resume them with a Range request
+	Limiter        *rateLimiter     // shared bandwidth cap, nil for none
+	Events         *eventStream     // progress events every ProgressEvery, nil for none
+	ProgressEvery  time.Duration
+}
+
+// contextReader fails reads once its context is cancelled so an in-flight
+// copy stops promptly on shutdown or timeout. Each successful read resets
+// the optional request timer, making it a stall timeout.
+type contextReader struct {
+	ctx   context.Context
+	r     io.Reader
+	timer *requestTimer
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := cr.r.Read(p)
+	if n > 0 && cr.timer != nil {
+		cr.timer.Reset()
+	}
+	return n, err
+}
+
+// errChecksumMismatch marks a download whose content does not match the object's MD5
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// errSimulatedFailure is the transient error injected by -simulate-failure-rate
+var errSimulatedFailure = errors.New("simulated failure")
+
+// downloadSingleFile downloads a single file to its layout path
+func downloadSingleFile(ctx context.Context, client ObjectStorageAPI, job Job, config Config, state *runState) (OperationResult, error) {
+	result := OperationResult{
+		LastAttempt: time.Now(),
+		ObjectPath:  job.ObjectName,
+	}
+
+	relPath, date, decompress := targetPath(job.ObjectName, config)
+	basePath := filepath.Join(config.DownloadFolder, filepath.FromSlash(relPath))
+	filePath := localPath(basePath, config.InferExtension)
+	result.FileName = path.Base(relPath)
+	result.RelativePath = relPath
+	result.ReportDate = date
+	if config.Sanitize != "" {
+		raw := config
+		raw.Sanitize, raw.Renames = "", nil
+		if rawPath, _, _ := targetPath(job.ObjectName, raw); path.Base(rawPath) != result.FileName {
+			result.OriginalFileName = path.Base(rawPath)
+			slog.Info("Sanitized file name", "object", job.ObjectName, "file", result.FileName)
+		}
+	}
+	if ext := strings.TrimPrefix(filePath, basePath); ext != "" {
+		if result.OriginalFileName == "" {
+			result.OriginalFileName = result.FileName
+		}
+		result.FileName += ext
+		result.RelativePath += ext
+	}
+	_, result.DateSource, _ = reportDate(job.ObjectName, config)
+
+	// Skip if already downloaded; only then is a HeadObject needed for the size.
+	// With ETag sidecars or a state file, the file is skipped only if its remote
+	// ETag is unchanged. With -overwrite the existing file is always replaced.
+	_, statErr := os.Stat(filePath)
+	exists := statErr == nil
+	if exists && !config.Overwrite {
+		meta, err := getObjectMetadata(ctx, client, config.Retry, job.Namespace, job.BucketName, job.ObjectName)
+		if err != nil {
+			slog.Warn("Could not get size", "object", job.ObjectName, "error", err)
+		}
+		unchanged := true
+		switch {
+		case config.ETagSidecars:
+			unchanged = meta.ETag != nil && readETagSidecar(filePath) == *meta.ETag
+		case state.manifest != nil:
+			unchanged = meta.ETag != nil && state.manifest.Unchanged(job.ObjectName, *meta.ETag)
+		}
+		if unchanged {
+			if meta.ContentLength != nil {
+				result.FileSize = *meta.ContentLength
+			}
+			result.Status = "Already exists"
+			result.Downloaded = false
+			return result, nil
+		}
+	}
+
+	// Archived objects cannot be read until restored; skip them for this run
+	if job.StorageTier == string(objectstorage.StorageTierArchive) && job.ArchivalState != string(objectstorage.ArchivalStateRestored) {
+		return needsRestore(ctx, client, job, config, result), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		result.Status = "Failed"
+		result.Error = err.Error()
+		result.ErrorClass = errorClass(err)
+		return result, err
+	}
+
+	// Download the file, retrying transient failures from scratch
+	opts := fetchOptions{
+		Verify:         config.VerifyChecksum,
+		Decompress:     decompress,
+		Progress:       state.progress,
+		RequestTimeout: config.Retry.RequestTimeout,
+		Limiter:        state.limiter,
+		Events:         state.events,
+		ProgressEvery:  config.ProgressEvery,
+		// A gzip stream cannot be picked up mid-way, so decompressed downloads restart
+		Resume: config.Resume && !decompress,
+	}
+	var transfer fetchResult
+	start := time.Now()
+	state.events.emit(event{Event: "started", Object: job.ObjectName, Path: relPath})
+	attempts, err := withRetry(ctx, config.Retry, "GetObject "+job.ObjectName, func() error {
+		if config.FailureRate > 0 && rand.Float64() < config.FailureRate {
+			return errSimulatedFailure
+		}
+		var err error
+		transfer, err = fetchObject(ctx, client, job, filePath, opts)
+		return err
+	})
+	result.Attempts = attempts
+	if isNotRestored(err) {
+		return needsRestore(ctx, client, job, config, result), nil
+	}
+	if err != nil {
+		// Report the size announced by GetObject, if it got that far
+		result.FileSize = transfer.ContentLength
+		result.Status = "Failed"
+		if errors.Is(err, errChecksumMismatch) {
+			result.Status = "Checksum mismatch"
+		} else if errors.Is(err, errRequestTimeout) {
+			result.Status = "Timeout"
+		} else if isNotFound(err) {
+			// Deleted between listing and download
+			result.Status = "Not found"
+		} else if errors.Is(err, context.Canceled) {
+			result.Status = "Cancelled"
+		}
+		result.Error = err.Error()
+		result.ErrorClass = errorClass(err)
+		return result, err
+	}
+
+	// Update with actual downloaded size
+	result.FileSize = transfer.Written
+	if decompress {
+		result.CompressedSize = transfer.Received
+		result.DecompressedSize = transfer.Written
+	}
+	result.Status = "Success"
+	if exists {
+		result.Status = "Overwritten"
+	}
+	result.Downloaded = true
+	result.Resumed = transfer.ResumedFrom > 0
+
+	// The extension is only known once GetObject returned the Content-Type
+	if config.InferExtension && filepath.Ext(filePath) == "" {
+		if ext := extensionForContentType(transfer.ContentType); ext != "" {
+			if err := os.Rename(filePath, filePath+ext); err != nil {
+				slog.Warn("Could not add inferred extension", "path", filePath, "content_type", transfer.ContentType, "error", err)
+			} else {
+				filePath += ext
+				if result.OriginalFileName == "" {
+					result.OriginalFileName = result.FileName
+				}
+				result.FileName += ext
+				result.RelativePath += ext
+			}
+		}
+	}
+	result.DurationMs = transfer.CopyDuration.Milliseconds()
+	if secs := transfer.CopyDuration.Seconds(); secs > 0 {
+		mbps := float64(transfer.Received-transfer.ResumedFrom) / secs / 1e6
+		result.ThroughputMBps = math.Round(mbps*100) / 100
+	}
+
+	if config.ValidateFocus {
+		missing, err := missingFocusColumns(filePath)
+		if err != nil {
+			result.Error = fmt.Sprintf("FOCUS validation: %v", err)
+		} else if len(missing) > 0 {
+			result.MissingColumns = missing
+			result.Error = "missing FOCUS columns: " + strings.Join(missing, ", ")
+		}
+		if result.Error != "" {
+			result.Status = "Invalid FOCUS"
+			slog.Warn("Downloaded file failed FOCUS validation", "object", job.ObjectName, "path", filePath, "error", result.Error)
+		}
+	}
+
+	if config.ETagSidecars && transfer.ETag != "" {
+		if err := os.WriteFile(etagSidecarPath(filePath), []byte(transfer.ETag+"\n"), 0644); err != nil {
+			slog.Warn("Could not write ETag sidecar", "path", etagSidecarPath(filePath), "error", err)
+		}
+	}
+
+	if state.manifest != nil {
+		entry := ManifestEntry{ETag: transfer.ETag, Size: transfer.Received, DownloadedAt: time.Now().UTC(), ReportDate: result.ReportDate}
+		if err := state.manifest.Record(job.ObjectName, entry); err != nil {
+			slog.Warn("Could not update state file", "path", config.StateFile, "error", err)
+		}
+	}
+
+	if len(config.PostHook) > 0 && (result.Status == "Success" || result.Status == "Overwritten") {
+		if err := runPostHook(ctx, config.PostHook, state.hooks, filePath, job, result, transfer.ETag); err != nil {
+			result.Status = "Post-hook failed"
+			result.Error = fmt.Sprintf("post-download command: %v", err)
+			slog.Warn("Post-download command failed", "object", job.ObjectName, "path", filePath, "error", err)
+		}
+	}
+
+	if !config.Quiet {
+		elapsed := time.Since(start)
+		slog.Info("Downloaded",
+			"object", job.ObjectName,
+			"size", transfer.Written,
+			"duration", elapsed.Round(time.Millisecond),
+			"mbps", fmt.Sprintf("%.2f", float64(transfer.Received-transfer.ResumedFrom)/elapsed.Seconds()/1e6),
+			"resumed_from", transfer.ResumedFrom,
+			"attempts", attempts,
+			"path", filePath)
+	}
+	return result, nil
+}
+
+// hookOutputLimit bounds the command output kept in the report when a
+// post-download command fails
+const hookOutputLimit = 500
+
+// runPostHook runs the -post-download-cmd for a downloaded file, with the
+// file path as the last argument and the object's details in FOCUS_*
+// environment variables. At most cap(slots) commands run at once, and a
+// cancelled run kills them.
+func runPostHook(ctx context.Context, command []string, slots chan struct{}, filePath string, job Job, result OperationResult, etag string) error {
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-slots }()
+
+	args := append(append([]string(nil), command[1:]...), filePath)
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Env = append(os.Environ(),
+		"FOCUS_OBJECT_NAME="+job.ObjectName,
+		"FOCUS_NAMESPACE="+job.Namespace,
+		"FOCUS_BUCKET="+job.BucketName,
+		"FOCUS_REPORT_DATE="+result.ReportDate,
+		"FOCUS_RELATIVE_PATH="+result.RelativePath,
+		"FOCUS_SIZE="+strconv.FormatInt(result.FileSize, 10),
+		"FOCUS_ETAG="+etag,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		// Keep the end of the output, where the reason usually is
+		out := strings.TrimSpace(string(output))
+		if len(out) > hookOutputLimit {
+			out = "..." + out[len(out)-hookOutputLimit:]
+		}
+		if out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// partialState is stored next to a partial download so that it is only
+// resumed while the object is unchanged, and can still be verified
+type partialState struct {
+	ETag string `json:"etag"`
+	MD5  string `json:"md5,omitempty"` // Content-MD5 of the whole object
+}
+
+// partialStatePath is where the partialState for the partial at tmpPath lives
+func partialStatePath(tmpPath string) string {
+	return tmpPath + ".json"
+}
+
+// resumablePartial returns the size and state of a partial download at
+// tmpPath, or 0 if there is none or it was written without a state file
+func resumablePartial(tmpPath string) (int64, partialState) {
+	var state partialState
+	data, err := os.ReadFile(partialStatePath(tmpPath))
+	if err != nil || json.Unmarshal(data, &state) != nil || state.ETag == "" {
+		return 0, partialState{}
+	}
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, partialState{}
+	}
+	return info.Size(), state
+}
+
+// removePartial deletes a partial download and its state
+func removePartial(tmpPath string) {
+	os.Remove(tmpPath)
+	os.Remove(partialStatePath(tmpPath))
+}
+
+// isStalePartial reports whether a ranged GetObject failed because the object
+// changed since the partial was written (412) or the range is past its end (416)
+func isStalePartial(err error) bool {
+	serviceErr, ok := common.IsServiceError(err)
+	return ok && (serviceErr.GetHTTPStatusCode() == 412 || serviceErr.GetHTTPStatusCode() == 416)
+}
+
+// continuesAt reports whether a ranged GetObject response is a 206 whose
+// Content-Range starts at offset. A server that ignores Range answers 200
+// with the whole object instead.
+func continuesAt(resp objectstorage.GetObjectResponse, offset int64) bool {
+	if resp.RawResponse != nil && resp.RawResponse.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	if resp.ContentRange == nil {
+		return false
+	}
+	var start int64
+	if _, err := fmt.Sscanf(*resp.ContentRange, "bytes %d-", &start); err != nil {
+		return false
+	}
+	return start == offset
+}
+
+// isNotFound reports whether the object no longer exists, also through the
+// wrapping of getObjectMetadata
+func isNotFound(err error) bool {
+	var serviceErr common.ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.GetHTTPStatusCode() == 404
+}
+
+// isNotRestored reports whether GetObject failed because the object is in the
+// Archive tier and has not been restored
+func isNotRestored(err error) bool {
+	serviceErr, ok := common.IsServiceError(err)
+	return ok && serviceErr.GetHTTPStatusCode() == 409 && serviceErr.GetCode() == "NotRestored"
+}
+
+// needsRestore marks an archived object as skipped with Status "Needs restore"
+// and, with config.AutoRestore, asks Object Storage to restore it. A
+// restore that was already requested is reported as "Restoring".
+func needsRestore(ctx context.Context, client ObjectStorageAPI, job Job, config Config, result OperationResult) OperationResult {
+	result.Status = "Needs restore"
+	if job.ArchivalState == string(objectstorage.ArchivalStateRestoring) {
+		result.Status = "Restoring"
+		return result
+	}
+	if !config.AutoRestore {
+		return result
+	}
+
+	req := objectstorage.RestoreObjectsRequest{
+		NamespaceName: &job.Namespace,
+		BucketName:    &job.BucketName,
+		RestoreObjectsDetails: objectstorage.RestoreObjectsDetails{
+			ObjectName: &job.ObjectName,
+			Hours:      common.Int(config.RestoreHours),
+		},
+	}
+	_, err := withRetry(ctx, config.Retry, "RestoreObjects "+job.ObjectName, func() error {
+		return callWithTimeout(ctx, config.Retry.RequestTimeout, func(ctx context.Context) error {
+			resp, err := client.RestoreObjects(ctx, req)
+			return withRetryAfter(err, resp.RawResponse)
+		})
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("restore request failed: %v", err)
+		result.ErrorClass = errorClass(err)
+		return result
+	}
+	result.Status = "Restoring"
+	slog.Info("Requested restore of archived object", "object", job.ObjectName, "hours", config.RestoreHours)
+	return result
+}
+
+// errorClass buckets a download error for the run statistics: timeout,
+// checksum, not_found, cancelled or other
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, errRequestTimeout) || errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errChecksumMismatch):
+		return "checksum"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, errSimulatedFailure):
+		return "simulated"
+	}
+	if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == 404 {
+		return "not_found"
+	}
+	return "other"
+}
+
+// fetchObject performs a single GetObject and writes the content to filePath.
+// The content is streamed to filePath+".tmp" and only renamed into place once
+// fully written, so an interrupted download never looks like a finished one.
+// When opts.Verify is set and the response carries a Content-MD5, the received
+// bytes are hashed while streaming and compared before the rename. When
+// opts.Decompress is set, the content is gunzipped on the way to disk.
+//
+// With opts.Resume, a failed transfer keeps its partial file together with the
+// object's ETag, and the next call asks only for the missing bytes with a Range
+// request conditioned on that ETag. If the object changed in the meantime, the
+// partial is discarded and the download starts over; so is it when the
+// response is not a 206 continuing at the partial's end.
+func fetchObject(ctx context.Context, client ObjectStorageAPI, job Job, filePath string, opts fetchOptions) (transfer fetchResult, err error) {
+	req := objectstorage.GetObjectRequest{
+		NamespaceName: &job.Namespace,
+		BucketName:    &job.BucketName,
+		ObjectName:    &job.ObjectName,
+	}
+
+	tmpPath := filePath + ".tmp"
+	var offset int64
+	var partial partialState
+	if opts.Resume {
+		offset, partial = resumablePartial(tmpPath)
+	}
+	if offset > 0 {
+		req.Range = common.String(fmt.Sprintf("bytes=%d-", offset))
+		req.IfMatch = common.String(partial.ETag)
+	}
+
+	reqCtx, timer := newRequestContext(ctx, opts.RequestTimeout)
+	defer timer.Stop()
+	defer func() { err = timeoutErr(reqCtx, err) }()
+
+	resp, err := client.GetObject(reqCtx, req)
+	if offset > 0 && isStalePartial(err) {
+		slog.Info("Partial download is stale, starting over", "object", job.ObjectName, "offset", offset, "error", err)
+		removePartial(tmpPath)
+		offset, partial = 0, partialState{}
+		req.Range, req.IfMatch = nil, nil
+		resp, err = client.GetObject(reqCtx, req)
+	}
+	if err != nil {
+		return transfer, withRetryAfter(err, resp.RawResponse)
+	}
+	if offset > 0 && !continuesAt(resp, offset) {
+		// Appending the whole object after the partial would corrupt the file,
+		// so write it from the start instead
+		slog.Info("Range request not honored, starting over", "object", job.ObjectName, "offset", offset)
+		offset, partial = 0, partialState{}
+	}
+	if resp.Content == nil {
+		// Zero-byte objects can come back without a body; anything larger cannot
+		if resp.ContentLength != nil && *resp.ContentLength > 0 {
+			return transfer, fmt.Errorf("GetObject returned no content for %s (%d bytes announced)", job.ObjectName, *resp.ContentLength)
+		}
+		resp.Content = http.NoBody
+	}
+	defer resp.Content.Close()
+	if resp.ContentLength != nil {
+		transfer.ContentLength = offset + *resp.ContentLength
+	}
+	if resp.ETag != nil {
+		transfer.ETag = *resp.ETag
+	}
+	if resp.ContentType != nil {
+		transfer.ContentType = *resp.ContentType
+	}
+	// A ranged response carries no MD5 for the whole object; use the one
+	// saved with the partial
+	contentMD5 := resp.ContentMd5
+	if offset > 0 {
+		transfer.ResumedFrom = offset
+		transfer.ETag = partial.ETag
+		contentMD5 = nil
+		if partial.MD5 != "" {
+			contentMD5 = &partial.MD5
+		}
+	}
+
+	var outFile *os.File
+	if offset > 0 {
+		outFile, err = os.OpenFile(tmpPath, os.O_RDWR, 0)
+	} else {
+		outFile, err = os.Create(tmpPath)
+	}
+	if err != nil {
+		return transfer, err
+	}
+	keepPartial := false
+	if opts.Resume && offset == 0 && transfer.ETag != "" {
+		state := partialState{ETag: transfer.ETag}
+		if contentMD5 != nil {
+			state.MD5 = *contentMD5
+		}
+		if data, err := json.Marshal(state); err == nil {
+			keepPartial = os.WriteFile(partialStatePath(tmpPath), data, 0644) == nil
+		}
+	} else if offset > 0 {
+		keepPartial = true
+	}
+	defer func() {
+		if err != nil {
+			outFile.Close()
+			// Corrupt data is never worth resuming
+			if !keepPartial || errors.Is(err, errChecksumMismatch) {
+				removePartial(tmpPath)
+			}
+		}
+	}()
+
+	hash := md5.New()
+	verify := opts.Verify && contentMD5 != nil
+	if offset > 0 {
+		// Hash what is already on disk, then append after it
+		if verify {
+			if _, err = io.Copy(hash, io.LimitReader(outFile, offset)); err != nil {
+				return transfer, err
+			}
+		}
+		if _, err = outFile.Seek(offset, io.SeekStart); err != nil {
+			return transfer, err
+		}
+	}
+
+	// The checksum covers the bytes as stored, before any decompression
+	var content io.Reader = resp.Content
+	if opts.Limiter != nil {
+		content = &throttledReader{ctx: reqCtx, r: content, limiter: opts.Limiter, timer: timer}
+	}
+	var body io.Reader = &contextReader{ctx: reqCtx, r: content, timer: timer}
+	if opts.Progress != nil {
+		opts.Progress.active.Add(1)
+		defer opts.Progress.active.Add(-1)
+		body = &progressReader{r: body, p: opts.Progress}
+	}
+	if opts.Events != nil && opts.ProgressEvery > 0 {
+		body = &eventProgressReader{r: body, events: opts.Events, object: job.ObjectName, size: transfer.ContentLength,
+			received: offset, interval: opts.ProgressEvery, last: time.Now()}
+	}
+	received := &countingReader{r: body}
+	var raw io.Reader = received
+	if verify {
+		raw = io.TeeReader(received, hash)
+	}
+
+	src := raw
+	if opts.Decompress {
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			return transfer, fmt.Errorf("invalid gzip stream for %s: %w", job.ObjectName, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	copyStart := time.Now()
+	transfer.Written, err = io.Copy(outFile, src)
+	transfer.CopyDuration = time.Since(copyStart)
+	transfer.Written += offset
+	if err != nil {
+		if opts.Decompress {
+			err = fmt.Errorf("decompressing %s: %w", job.ObjectName, err)
+		}
+		return transfer, err
+	}
+	// Drain anything the gzip reader left unread so the checksum sees it all
+	if _, err = io.Copy(io.Discard, raw); err != nil {
+		return transfer, err
+	}
+	transfer.Received = offset + received.n
+
+	if verify {
+		if got := base64.StdEncoding.EncodeToString(hash.Sum(nil)); got != *contentMD5 {
+			return transfer, fmt.Errorf("%w for %s: expected MD5 %s, got %s", errChecksumMismatch, job.ObjectName, *contentMD5, got)
+		}
+	}
+
+	if err = outFile.Sync(); err != nil {
+		return transfer, err
+	}
+	if err = outFile.Close(); err != nil {
+		return transfer, err
+	}
+	if err = os.Rename(tmpPath, filePath); err != nil {
+		return transfer, err
+	}
+	os.Remove(partialStatePath(tmpPath))
+	return transfer, nil
+}
+
+// gzipFile closes both the gzip reader and the file underneath it
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
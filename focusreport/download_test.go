@@ -0,0 +1,203 @@
+package focusreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadSingleFile(t *testing.T) {
+	name := "FOCUS Reports/2024/03/15/0001.csv.gz"
+	client := newFakeClient(map[string]string{name: "first report"})
+	config := testConfig(t)
+	job := Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}
+
+	result, err := downloadSingleFile(context.Background(), client, job, config, &runState{progress: newProgressTracker()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FileName != "20240315_0001.csv.gz" || result.Status != "Success" || !result.Downloaded || result.FileSize != 12 {
+		t.Errorf("result %+v, want 20240315_0001.csv.gz downloaded with 12 bytes", result)
+	}
+	data, err := os.ReadFile(filepath.Join(config.DownloadFolder, result.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first report" {
+		t.Errorf("%s holds %q, want %q", result.FileName, data, "first report")
+	}
+
+	client.gets.Store(0)
+	result, err = downloadSingleFile(context.Background(), client, job, config, &runState{progress: newProgressTracker()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "Already exists" || result.Downloaded || client.gets.Load() != 0 {
+		t.Errorf("rerun has status %q after %d GETs, want Already exists without a GET", result.Status, client.gets.Load())
+	}
+}
+
+func TestDownloadSingleFileSkipsHeadObject(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "first report",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "second report",
+		"FOCUS Reports/2024/03/17/0001.csv.gz": "third report",
+	})
+	config := testConfig(t)
+	download := func() (skipped int) {
+		for _, name := range []string{
+			"FOCUS Reports/2024/03/15/0001.csv.gz",
+			"FOCUS Reports/2024/03/16/0001.csv.gz",
+			"FOCUS Reports/2024/03/17/0001.csv.gz",
+		} {
+			result, err := downloadSingleFile(context.Background(), client, Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}, config, &runState{progress: newProgressTracker()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.FileSize != int64(len(client.objects[name])) {
+				t.Errorf("%s reported %d bytes, want %d", name, result.FileSize, len(client.objects[name]))
+			}
+			if result.Status == "Already exists" {
+				skipped++
+			}
+		}
+		return skipped
+	}
+
+	download()
+	if heads, gets := client.heads.Load(), client.gets.Load(); heads != 0 || gets != 3 {
+		t.Errorf("first run made %d HEADs and %d GETs, want 0 and 3", heads, gets)
+	}
+
+	client.heads.Store(0)
+	client.gets.Store(0)
+	skipped := download()
+	if heads, gets := client.heads.Load(), client.gets.Load(); heads != 3 || gets != 0 {
+		t.Errorf("rerun made %d HEADs and %d GETs, want 3 and 0", heads, gets)
+	}
+	if skipped != 3 {
+		t.Errorf("rerun skipped %d files, want 3", skipped)
+	}
+}
+
+func TestDownloadSingleFileNilContent(t *testing.T) {
+	empty, announced := "FOCUS Reports/2024/03/15/empty.csv", "FOCUS Reports/2024/03/15/0001.csv.gz"
+	client := newFakeClient(map[string]string{empty: "", announced: "report"})
+	client.nilContent = true
+	config := testConfig(t)
+	config.Retry.MaxRetries = 0
+	download := func(name string) (OperationResult, error) {
+		return downloadSingleFile(context.Background(), client, Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}, config, &runState{progress: newProgressTracker()})
+	}
+
+	result, err := download(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(config.DownloadFolder, result.RelativePath))
+	if err != nil || len(data) != 0 || !result.Downloaded {
+		t.Errorf("zero-byte object: downloaded %v, file %q, %v; want an empty file", result.Downloaded, data, err)
+	}
+
+	result, err = download(announced)
+	if err == nil || result.Status != "Failed" || !strings.Contains(result.Error, "no content") {
+		t.Errorf("object with a length but no content has status %q, error %v; want Failed with no content", result.Status, err)
+	}
+}
+
+// throttledOnce fails the first GetObject of each object with a 429 carrying
+// retryAfter, and records when each attempt was made
+func throttledOnce(retryAfter string, mu *sync.Mutex, at *[]time.Time) func(string, int) (*http.Response, error) {
+	return func(name string, attempt int) (*http.Response, error) {
+		mu.Lock()
+		*at = append(*at, time.Now())
+		mu.Unlock()
+		if attempt > 1 {
+			return nil, nil
+		}
+		raw := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		raw.Header.Set("Retry-After", retryAfter)
+		return raw, fakeServiceError{status: http.StatusTooManyRequests, code: "TooManyRequests"}
+	}
+}
+
+func TestDownloadSingleFileHonorsRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		retryAfter string
+		maxDelay   time.Duration
+		min, max   time.Duration // bounds of the observed wait
+	}{
+		{"server delay", "1", 5 * time.Second, time.Second, 2 * time.Second},
+		{"capped at max delay", "120", 200 * time.Millisecond, 200 * time.Millisecond, time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			name := "FOCUS Reports/2024/03/15/0001.csv.gz"
+			client := newFakeClient(map[string]string{name: "report"})
+			var mu sync.Mutex
+			var at []time.Time
+			client.getErr = throttledOnce(tc.retryAfter, &mu, &at)
+			config := testConfig(t)
+			config.Retry.MaxDelay = tc.maxDelay
+
+			result, err := downloadSingleFile(context.Background(), client, Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}, config, &runState{progress: newProgressTracker()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Downloaded || len(at) != 2 {
+				t.Fatalf("downloaded %v in %d attempts, want a download in 2", result.Downloaded, len(at))
+			}
+			if wait := at[1].Sub(at[0]); wait < tc.min || wait > tc.max {
+				t.Errorf("waited %v before retrying, want between %v and %v", wait, tc.min, tc.max)
+			}
+		})
+	}
+}
+
+func TestFetchObjectResume(t *testing.T) {
+	name := "FOCUS Reports/2024/03/15/0001.csv.gz"
+	for _, tc := range []struct {
+		name        string
+		ignoreRange bool
+		resumedFrom int64
+	}{
+		{"range honored", false, 6},
+		{"range ignored", true, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient(map[string]string{name: "first report"})
+			client.ignoreRange = tc.ignoreRange
+			filePath := filepath.Join(t.TempDir(), "20240315_0001.csv.gz")
+			// A partial left by an interrupted download of the same version
+			if err := os.WriteFile(filePath+".tmp", []byte("first "), 0644); err != nil {
+				t.Fatal(err)
+			}
+			state := fmt.Sprintf(`{"etag": %q}`, fakeETag(client.objects[name]))
+			if err := os.WriteFile(partialStatePath(filePath+".tmp"), []byte(state), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			job := Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}
+			transfer, err := fetchObject(context.Background(), client, job, filePath, fetchOptions{Resume: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transfer.ResumedFrom != tc.resumedFrom || transfer.Written != 12 {
+				t.Errorf("resumed from %d and wrote %d bytes, want %d and 12", transfer.ResumedFrom, transfer.Written, tc.resumedFrom)
+			}
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "first report" {
+				t.Errorf("file holds %q, want %q", data, "first report")
+			}
+		})
+	}
+}
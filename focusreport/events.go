@@ -0,0 +1,91 @@
+package focusreport
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// event is one line of the -events jsonl stream
+type event struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"` // listed, started, progress, completed or failed
+	Object     string    `json:"object"`
+	Path       string    `json:"path,omitempty"`  // relative to the download folder
+	Size       int64     `json:"size,omitempty"`  // object size, when known
+	Bytes      int64     `json:"bytes,omitempty"` // received so far, for progress
+	Status     string    `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+}
+
+// eventStream writes events as JSON Lines, one complete line per write so a
+// reader tailing the output never sees a partial event. A nil stream
+// discards events.
+type eventStream struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newEventStream(w io.Writer) *eventStream {
+	return &eventStream{w: w}
+}
+
+// emit stamps e with the current time and writes it
+func (s *eventStream) emit(e event) {
+	if s == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(line, '\n'))
+}
+
+// finished emits the completed or failed event for a download job
+func (s *eventStream) finished(result OperationResult, objectName string, err error, elapsed time.Duration) {
+	e := event{
+		Event:      "completed",
+		Object:     objectName,
+		Path:       result.RelativePath,
+		Size:       result.FileSize,
+		Status:     result.Status,
+		Error:      result.Error,
+		ErrorClass: result.ErrorClass,
+		Attempts:   result.Attempts,
+		DurationMs: elapsed.Milliseconds(),
+	}
+	if err != nil {
+		e.Event = "failed"
+	}
+	s.emit(e)
+}
+
+// eventProgressReader emits progress events for one download, at most one
+// per interval
+type eventProgressReader struct {
+	r        io.Reader
+	events   *eventStream
+	object   string
+	size     int64
+	received int64 // including a resumed offset
+	interval time.Duration
+	last     time.Time
+}
+
+func (er *eventProgressReader) Read(b []byte) (int, error) {
+	n, err := er.r.Read(b)
+	er.received += int64(n)
+	if now := time.Now(); now.Sub(er.last) >= er.interval {
+		er.last = now
+		er.events.emit(event{Event: "progress", Object: er.object, Size: er.size, Bytes: er.received})
+	}
+	return n, err
+}
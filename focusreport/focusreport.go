@@ -0,0 +1,648 @@
+// Package focusreport lists the FOCUS cost reports an OCI tenancy exports to
+// Object Storage, downloads them and writes the operation and summary reports.
+// Run does one pass for a Config against any ObjectStorageAPI; the
+// oci_focus_download command in the module root fills the Config from its flags.
+package focusreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// Configuration
+type Config struct {
+	MaxWorkers     int
+	MetaWorkers    int // HeadObject calls in flight while listing and summarizing, 0 for MaxWorkers
+	Days           int
+	DownloadFolder string
+	ReportFile     string
+	FromDate       time.Time // inclusive lower bound, zero when unset
+	ToDate         time.Time // inclusive upper bound, zero when unset
+	Retry          RetryConfig
+	AuthMethod     string // config, instance-principal or resource-principal
+	OCIConfigFile  string
+	OCIProfile     string
+	Namespace      string
+	BucketName     string
+	VerifyChecksum bool
+	Decompress     bool
+	ReportFormat   string // csv or json
+	ProgressEvery  time.Duration
+	Checkpoint     time.Duration // rewrite the report during downloads, 0 disables
+	Quiet          bool
+	DryRun         bool
+	Region         string
+	NamePattern    *regexp.Regexp // objects must match to be listed
+	Include        []string       // globs, at least one must match when set
+	Exclude        []string       // globs, any match drops the object
+	StateFile      string
+	MinSize        int64            // bytes, 0 means no lower bound
+	MaxSize        int64            // bytes, 0 means no upper bound
+	MaxTotalBytes  int64            // download budget in bytes, 0 means unlimited
+	MaxObjects     int              // stop listing after this many matching objects, 0 means all
+	PageSize       int              // ListObjects Limit, 0 for MaxPageSize
+	MaxBandwidth   int64            // bytes per second across all workers, 0 means unlimited
+	SummaryFile    string           // empty disables the summary CSV
+	ForceHead      bool             // always HeadObject for sizes, ignoring listing sizes
+	NoSizes        bool             // never HeadObject for sizes; unlisted sizes are left empty
+	StdoutFormat   string           // text or json
+	Layout         string           // flat or partitioned
+	Overwrite      bool             // re-download files that already exist locally
+	ValidateFocus  bool             // check downloaded CSV headers for the FOCUS columns
+	CostSummary    string           // BilledCost per currency CSV, empty disables it
+	Resume         bool             // resume partial downloads instead of starting over
+	Prefix         string           // server-side object name prefix, empty lists the whole bucket
+	Prefixes       []string         // several prefixes, listed concurrently by listFocusReports
+	Dates          *DateIndex       // metadata report dates with -date-source metadata, nil otherwise
+	NameTemplate   FilenameTemplate // flat layout file names, nil for the default
+	InferExtension bool             // append an extension from the Content-Type to names without one
+	Deterministic  bool             // queue jobs and write report rows in object name order
+	Sanitize       string           // replacement for characters invalid in Windows file names, empty keeps them
+	PostHook       []string         // command run after each download, with the file path appended
+	HookWorkers    int              // PostHook commands running at once
+	FailureRate    float64          // probability of a synthetic failure per GetObject attempt, testing only
+	AutoRestore    bool             // request a restore of archived objects
+	RestoreHours   int              // how long restored objects stay readable
+
+	// Collision-free relative paths by object name, see -on-collision
+	Renames map[string]string
+
+	// Options of Run
+	TenancyID    string                 // summary CSV tenancy_ocid column
+	ObjectList   string                 // file of object names to fetch instead of listing the bucket
+	ObjectColumn string                 // ObjectList CSV column, empty for object_path or object_name
+	ResumeReport string                 // prior operation report whose failed rows are retried instead of listing
+	ObjectsJSON  string                 // matched objects written as JSON before any download, "-" for stdout
+	SinceLastRun bool                   // only list reports dated after the latest one in StateFile
+	Latest       int                    // keep only this many most recent reports, 0 keeps all
+	OnCollision  string                 // hash, error or overwrite; see -on-collision
+	CompareOnly  bool                   // compare DownloadFolder with the bucket instead of downloading
+	CompareFile  string                 // comparison CSV written with CompareOnly
+	VerifyOnly   bool                   // audit the files in DownloadFolder against the bucket instead of downloading
+	VerifyFile   string                 // verification CSV written with VerifyOnly
+	MetricsAddr  string                 // Prometheus listen address, empty disables it
+	Events       string                 // "jsonl" streams download events on stdout, empty disables it
+	ETagSidecars bool                   // keep each file's ETag in <file>.etag, skip only unchanged files
+	SummaryLess  func(a, b Report) bool // summary CSV order, nil for date descending
+	SummarySplit string                 // "month" also writes one summary CSV per report month, empty disables it
+	SplitOnly    bool                   // with SummarySplit, skip the combined summary CSV
+
+	// Set by RunRegions to collect the summary rows instead of writing them
+	summaryRows *[]Report
+}
+
+// Stats is the end-of-run summary printed on stdout
+type Stats struct {
+	Listed     int           `json:"listed"`
+	Attempted  int           `json:"attempted"` // files with an operation result
+	Downloaded int           `json:"downloaded"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	TotalBytes int64         `json:"total_bytes"`
+	Throughput float64       `json:"throughput_mbps"` // TotalBytes over the whole run, in MB/s
+	Elapsed    time.Duration `json:"-"`
+	ElapsedSec float64       `json:"elapsed_seconds"`
+	// Failed files by errorClass
+	FailuresByClass map[string]int `json:"failures_by_class,omitempty"`
+}
+
+// OperationResult tracks download results
+type OperationResult struct {
+	FileName     string    `json:"file_name"`
+	RelativePath string    `json:"relative_path"` // path within DownloadFolder
+	FileSize     int64     `json:"file_size"`
+	ReportDate   string    `json:"report_date"`
+	DateSource   string    `json:"date_source"` // path or metadata
+	Status       string    `json:"status"`
+	Downloaded   bool      `json:"downloaded"`
+	Error        string    `json:"error,omitempty"`
+	LastAttempt  time.Time `json:"last_attempt"`
+	Attempts     int       `json:"attempts"`
+	// Set only when a .gz object was decompressed on download
+	CompressedSize   int64  `json:"compressed_size,omitempty"`
+	DecompressedSize int64  `json:"decompressed_size,omitempty"`
+	ErrorClass       string `json:"error_class,omitempty"` // see errorClass
+	// Set only with -validate-focus when the header lacks required columns
+	MissingColumns []string `json:"missing_columns,omitempty"`
+	Resumed        bool     `json:"resumed"` // continued from a partial download
+	// Time spent receiving the content on the last attempt, and the rate over
+	// the bytes received in it
+	DurationMs     int64   `json:"duration_ms"`
+	ThroughputMBps float64 `json:"throughput_mbps"`
+	// Set only when -sanitize or -infer-extension changed the file name
+	OriginalFileName string `json:"original_file_name,omitempty"`
+	ObjectPath       string `json:"object_path"` // full object name, used by -resume-report
+}
+
+// Job represents a file to download
+type Job struct {
+	ObjectName    string
+	Namespace     string
+	BucketName    string
+	StorageTier   string // from the listing, empty if unknown
+	ArchivalState string // from the listing, empty if unknown
+	seq           int64  // queue position, assigned by AddJob
+}
+
+// runState is the per-run state shared by all download workers
+type runState struct {
+	progress *progressTracker
+	manifest *Manifest    // nil unless -state-file is set
+	metrics  *metrics     // nil unless -metrics-addr is set
+	limiter  *rateLimiter // nil unless -max-bandwidth is set
+	events   *eventStream // nil unless -events is set
+	// PostHook slots, nil unless -post-download-cmd is set
+	hooks chan struct{}
+	// -resume-report rows the results are merged into, nil otherwise
+	prior []OperationResult
+}
+
+// Result represents the outcome of processing a job
+type Result struct {
+	Job    Job
+	Result OperationResult
+	Error  error
+}
+
+// ObjectStorageAPI is the subset of the Object Storage client used for listing
+// and downloading, so it can be replaced by an in-memory fake
+type ObjectStorageAPI interface {
+	ListObjects(ctx context.Context, request objectstorage.ListObjectsRequest) (objectstorage.ListObjectsResponse, error)
+	HeadObject(ctx context.Context, request objectstorage.HeadObjectRequest) (objectstorage.HeadObjectResponse, error)
+	GetObject(ctx context.Context, request objectstorage.GetObjectRequest) (objectstorage.GetObjectResponse, error)
+	RestoreObjects(ctx context.Context, request objectstorage.RestoreObjectsRequest) (objectstorage.RestoreObjectsResponse, error)
+}
+
+// stringValue dereferences an optional SDK string
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// metadataWorkers is the number of HeadObject calls run at once outside the
+// downloads
+func (c Config) metadataWorkers() int {
+	if c.MetaWorkers > 0 {
+		return c.MetaWorkers
+	}
+	return c.MaxWorkers
+}
+
+// Run lists the FOCUS reports in config.BucketName and, depending on config,
+// downloads them to config.DownloadFolder, writes the dry-run plan or
+// compares them with the local folder. It writes the operation report, the
+// stats file, the cost summary and the summary CSV that config asks for and
+// returns the run summary with the per-file results.
+//
+// config must be validated as main does for the command line, with
+// config.Namespace and config.BucketName resolved. When ctx is cancelled
+// during the downloads, the results so far are still written and returned
+// together with the context's error. When the run completes but some files
+// failed, the error is a *DownloadErrors holding the per-file errors.
+func Run(ctx context.Context, config Config, client ObjectStorageAPI) (Stats, []OperationResult, error) {
+	start := time.Now()
+	namespace, bucketName := config.Namespace, config.BucketName
+	client = newHeadCacheClient(client)
+
+	var manifest *Manifest
+	if config.StateFile != "" {
+		var err error
+		if manifest, err = LoadManifest(config.StateFile); err != nil {
+			return Stats{}, nil, fmt.Errorf("loading state file %s: %w", config.StateFile, err)
+		}
+	}
+	if config.SinceLastRun {
+		if manifest == nil {
+			return Stats{}, nil, errors.New("listing since the last run requires a state file")
+		}
+		if latest, ok := manifest.LatestReportDate(); ok {
+			config.FromDate = latest.AddDate(0, 0, 1)
+			slog.Info("Listing reports newer than the last run", "latest_downloaded", latest.Format("2006-01-02"))
+		} else {
+			slog.Info("No report dates in the state file yet, using the -days or -to window")
+		}
+	}
+
+	// Create download directory if specified
+	if config.DownloadFolder != "" && !config.DryRun && !config.CompareOnly && !config.VerifyOnly {
+		if err := os.MkdirAll(config.DownloadFolder, 0755); err != nil {
+			return Stats{}, nil, fmt.Errorf("creating download folder: %w", err)
+		}
+	}
+
+	// List all FOCUS reports, or check the ones named by -object-list or
+	// left to retry by -resume-report
+	var objects []objectstorage.ObjectSummary
+	var missing []string
+	var prior []OperationResult
+	var capped string // noted in the progress output when -max-objects cut the listing
+	if config.ObjectList != "" || config.ResumeReport != "" {
+		var names []string
+		source := config.ObjectList
+		if config.ResumeReport != "" {
+			var err error
+			if prior, err = readOperationReport(config.ResumeReport); err != nil {
+				return Stats{}, nil, fmt.Errorf("reading report %s: %w", config.ResumeReport, err)
+			}
+			names = retryableObjects(prior)
+			source = config.ResumeReport
+		} else {
+			var err error
+			if names, err = readObjectList(config.ObjectList, config.ObjectColumn); err != nil {
+				return Stats{}, nil, fmt.Errorf("reading object list %s: %w", config.ObjectList, err)
+			}
+		}
+		objects, missing = headObjects(ctx, client, config, namespace, bucketName, names)
+		if ctx.Err() != nil {
+			return Stats{}, nil, fmt.Errorf("checking listed objects: %w", ctx.Err())
+		}
+		if config.Dates != nil {
+			found := make([]string, len(objects))
+			for i, obj := range objects {
+				found[i] = *obj.Name
+			}
+			config.Dates.Resolve(ctx, client, config.Retry, config.metadataWorkers(), namespace, bucketName, found)
+		}
+		for _, name := range missing {
+			slog.Warn("Listed object does not exist", "object", name)
+		}
+		fmt.Fprintf(os.Stderr, "Found %d of %d objects from %s in bucket %s\n", len(objects), len(names), source, bucketName)
+	} else {
+		var truncated bool
+		var err error
+		objects, truncated, err = listFocusReports(ctx, client, config, namespace, bucketName)
+		if err != nil {
+			if len(objects) == 0 || ctx.Err() != nil {
+				return Stats{}, nil, fmt.Errorf("listing FOCUS reports: %w", err)
+			}
+			slog.Warn("Listing incomplete, continuing with partial results", "objects", len(objects), "error", err)
+		}
+		fmt.Fprintf(os.Stderr, "Found %d FOCUS reports in bucket %s\n", len(objects), bucketName)
+		if truncated {
+			capped = fmt.Sprintf(", capped by -max-objects %d", config.MaxObjects)
+			fmt.Fprintf(os.Stderr, "Listing stopped at -max-objects %d; the bucket may hold more matching reports\n", config.MaxObjects)
+		}
+	}
+
+	listed := len(objects)
+	var events *eventStream
+	if config.Events == "jsonl" {
+		events = newEventStream(os.Stdout)
+	}
+	for _, obj := range objects {
+		if obj.Name == nil {
+			continue
+		}
+		e := event{Event: "listed", Object: *obj.Name}
+		if obj.Size != nil {
+			e.Size = *obj.Size
+		}
+		events.emit(e)
+	}
+
+	// Keep only the most recent reports with -latest
+	var trimmed []objectstorage.ObjectSummary
+	if config.Latest > 0 && len(objects) > config.Latest {
+		objects, trimmed = latestReports(objects, config.Latest, config)
+		fmt.Fprintf(os.Stderr, "Keeping the latest %d of %d FOCUS reports\n", len(objects), listed)
+	}
+	if config.Deterministic {
+		sort.SliceStable(objects, func(i, j int) bool {
+			return stringValue(objects[i].Name) < stringValue(objects[j].Name)
+		})
+	}
+	if config.ObjectsJSON != "" {
+		if err := writeObjectsJSON(objects, config, config.ObjectsJSON); err != nil {
+			return Stats{Listed: listed}, nil, fmt.Errorf("writing objects JSON %s: %w", config.ObjectsJSON, err)
+		}
+		if config.ObjectsJSON != "-" {
+			fmt.Fprintf(os.Stderr, "Objects written to: %s (%d objects)\n", config.ObjectsJSON, len(objects))
+		}
+	}
+
+	// Resolve sizes up front when they decide what gets downloaded
+	var reports, planned []Report
+	var downloadResults []OperationResult
+	var failures error // *DownloadErrors once some downloads failed
+	if config.DryRun || config.CompareOnly || config.VerifyOnly || config.MinSize > 0 || config.MaxSize > 0 || config.MaxTotalBytes > 0 {
+		reports = collectReports(ctx, client, config, namespace, bucketName, objects)
+		if config.MinSize > 0 || config.MaxSize > 0 {
+			reports = filterBySize(reports, config.MinSize, config.MaxSize)
+			objects = objectsInReports(objects, reports)
+			fmt.Fprintf(os.Stderr, "%d FOCUS reports within the size limits\n", len(objects))
+		}
+		planned = reports
+		if config.MaxTotalBytes > 0 && (config.DryRun || config.DownloadFolder != "") {
+			// Over-budget objects are reported but stay in the summary CSV
+			var skipped []Report
+			planned, skipped = applyByteBudget(reports, config.MaxTotalBytes)
+			objects = objectsInReports(objects, planned)
+			downloadResults = append(downloadResults, budgetSkippedResults(skipped, config)...)
+		}
+	}
+
+	// Resolve objects that would overwrite each other's local file
+	if config.DryRun || config.DownloadFolder != "" {
+		collisions := findCollisions(objects, config)
+		paths := make([]string, 0, len(collisions))
+		for relPath := range collisions {
+			paths = append(paths, relPath)
+		}
+		sort.Strings(paths)
+		switch {
+		case len(paths) == 0:
+		case config.OnCollision == "error":
+			for _, relPath := range paths {
+				fmt.Fprintf(os.Stderr, "%s <- %s\n", relPath, strings.Join(collisions[relPath], ", "))
+			}
+			return Stats{Listed: listed}, nil, fmt.Errorf("%d local files would be written by several objects; use -on-collision hash or a -filename-template that keeps them apart", len(paths))
+		case config.OnCollision == "hash":
+			config.Renames = make(map[string]string)
+			for _, relPath := range paths {
+				for _, name := range collisions[relPath] {
+					config.Renames[name] = disambiguate(relPath, name)
+				}
+			}
+			slog.Warn("Several objects map to the same local file, adding a hash of the object name", "collisions", len(paths), "files", len(config.Renames))
+		default:
+			for _, relPath := range paths {
+				slog.Warn("Several objects map to the same local file, the last download wins", "path", relPath, "objects", collisions[relPath])
+			}
+		}
+	}
+
+	// Report the differences with the local folder only
+	if config.CompareOnly {
+		results := compareLocal(reports, config)
+		if err := writeComparisonCSV(results, config.CompareFile); err != nil {
+			return Stats{Listed: listed}, nil, fmt.Errorf("writing comparison report %s: %w", config.CompareFile, err)
+		}
+		counts := make(map[string]int)
+		for _, r := range results {
+			counts[r.Status]++
+		}
+		fmt.Fprintf(os.Stderr, "Comparison written to: %s (%d present, %d missing, %d size mismatched)\n",
+			config.CompareFile, counts["present"], counts["missing"], counts["size_mismatch"])
+		return computeStats(listed, nil, time.Since(start)), nil, nil
+	}
+
+	// Audit the local files without downloading
+	if config.VerifyOnly {
+		results, err := verifyLocal(reports, manifest, config)
+		if err != nil {
+			return Stats{Listed: listed}, nil, fmt.Errorf("verifying %s: %w", config.DownloadFolder, err)
+		}
+		if err := writeVerifyCSV(results, config.VerifyFile); err != nil {
+			return Stats{Listed: listed}, nil, fmt.Errorf("writing verification report %s: %w", config.VerifyFile, err)
+		}
+		counts := make(map[string]int)
+		for _, r := range results {
+			counts[r.Status]++
+		}
+		fmt.Fprintf(os.Stderr, "Verification written to: %s (%d OK, %d size mismatched, %d ETag mismatched, %d missing remote, %d unmatched)\n",
+			config.VerifyFile, counts["OK"], counts["SizeMismatch"], counts["EtagMismatch"], counts["MissingRemote"], counts["UnmatchedLocal"])
+		return computeStats(listed, nil, time.Since(start)), nil, nil
+	}
+
+	if len(trimmed) > 0 && (config.DryRun || config.DownloadFolder != "") {
+		downloadResults = append(downloadResults, trimmedResults(trimmed, config)...)
+	}
+	if len(missing) > 0 && (config.DryRun || config.DownloadFolder != "") {
+		downloadResults = append(downloadResults, missingResults(missing, config)...)
+	}
+
+	// Download reports if folder provided
+	if config.DryRun {
+		// Plan only: print what would be fetched and archive the plan
+		downloadResults = append(planDownloads(planned, config), downloadResults...)
+		if config.Deterministic {
+			sortByObjectName(downloadResults)
+		}
+		if err := writeReport(downloadResults, config.ReportFile, config.ReportFormat); err != nil {
+			return Stats{Listed: listed}, downloadResults, fmt.Errorf("writing operation report %s: %w", config.ReportFile, err)
+		}
+		fmt.Fprintf(os.Stderr, "Dry-run plan written to: %s\n", config.ReportFile)
+	} else if config.DownloadFolder != "" {
+		// Create worker pool
+		state := &runState{progress: newProgressTracker(), manifest: manifest, events: events, prior: prior}
+		if len(config.PostHook) > 0 {
+			state.hooks = make(chan struct{}, config.HookWorkers)
+		}
+		if config.MaxBandwidth > 0 {
+			state.limiter = newRateLimiter(config.MaxBandwidth)
+		}
+		if config.MetricsAddr != "" {
+			state.metrics = newMetrics()
+			if err := serveMetrics(ctx, config.MetricsAddr, state.metrics); err != nil {
+				return Stats{Listed: listed}, nil, fmt.Errorf("starting metrics server on %s: %w", config.MetricsAddr, err)
+			}
+		}
+		pool := NewWorkerPool(ctx, client, config, state)
+		pool.Start()
+
+		// Add jobs to queue
+		fmt.Fprintf(os.Stderr, "Starting %d workers to process %d files%s...\n", config.MaxWorkers, len(objects), capped)
+		startTime := time.Now()
+
+		for _, obj := range objects {
+			if obj.Name == nil {
+				continue
+			}
+			err := pool.AddJob(Job{
+				ObjectName:    *obj.Name,
+				Namespace:     namespace,
+				BucketName:    bucketName,
+				StorageTier:   string(obj.StorageTier),
+				ArchivalState: string(obj.ArchivalState),
+			})
+			if err != nil {
+				slog.Warn("Interrupted, not queuing remaining files")
+				break
+			}
+		}
+
+		// Wait for completion
+		for _, result := range pool.Collect() {
+			downloadResults = append(downloadResults, result.Result)
+		}
+		failures = newDownloadErrors(pool.Errors())
+		if config.Deterministic {
+			sortByObjectName(downloadResults)
+		}
+
+		totalTime := time.Since(startTime)
+		fmt.Fprintf(os.Stderr, "Download completed in %v\n", totalTime)
+
+		// Write operation report, updating the rows of a resumed report
+		report := downloadResults
+		if prior != nil {
+			report = mergeResults(prior, downloadResults)
+		}
+		if err := writeReport(report, config.ReportFile, config.ReportFormat); err != nil {
+			return computeStats(listed, downloadResults, time.Since(start)), downloadResults, fmt.Errorf("writing operation report %s: %w", config.ReportFile, err)
+		}
+		fmt.Fprintf(os.Stderr, "Download operation report generated: %s\n", config.ReportFile)
+		if ctx.Err() != nil {
+			stats := computeStats(listed, downloadResults, time.Since(start))
+			if err := writeStatsFile(stats, statsFilePath(config.ReportFile)); err != nil {
+				slog.Warn("Could not write stats file", "path", statsFilePath(config.ReportFile), "error", err)
+			}
+			return stats, downloadResults, ctx.Err()
+		}
+		fmt.Fprintf(os.Stderr, "Reports downloaded successfully to folder: %s\n", config.DownloadFolder)
+
+		// Archived reports are left for a later run, once restored
+		var archived []string
+		for _, r := range downloadResults {
+			if r.Status == "Needs restore" || r.Status == "Restoring" {
+				archived = append(archived, fmt.Sprintf("  %s (%s)", r.RelativePath, r.Status))
+			}
+		}
+		if len(archived) > 0 {
+			hint := "rerun with -auto-restore to request a restore"
+			if config.AutoRestore {
+				hint = "rerun once the restores complete, usually within an hour"
+			}
+			fmt.Fprintf(os.Stderr, "%d archived reports were not downloaded; %s:\n%s\n", len(archived), hint, strings.Join(archived, "\n"))
+		}
+
+		if config.CostSummary != "" {
+			totals, failed := summarizeCost(downloadResults, config.DownloadFolder)
+			if err := writeCostSummary(totals, config.CostSummary); err != nil {
+				return computeStats(listed, downloadResults, time.Since(start)), downloadResults, fmt.Errorf("writing cost summary %s: %w", config.CostSummary, err)
+			}
+			fmt.Fprintf(os.Stderr, "Cost summary generated: %s (%d currencies, %d files skipped)\n", config.CostSummary, len(totals), failed)
+		}
+	}
+
+	if config.SummaryFile != "" || config.summaryRows != nil {
+		// Generate summary CSV with correct sizes
+		if reports == nil {
+			reports = collectReports(ctx, client, config, namespace, bucketName, objects)
+		}
+		if config.summaryRows != nil {
+			*config.summaryRows = append(*config.summaryRows, reports...)
+		} else if err := writeSummaries(reports, config, capped); err != nil {
+			return computeStats(listed, downloadResults, time.Since(start)), downloadResults, err
+		}
+	}
+
+	stats := computeStats(listed, downloadResults, time.Since(start))
+	if config.DryRun || config.DownloadFolder != "" {
+		if err := writeStatsFile(stats, statsFilePath(config.ReportFile)); err != nil {
+			slog.Warn("Could not write stats file", "path", statsFilePath(config.ReportFile), "error", err)
+		}
+	}
+	return stats, downloadResults, failures
+}
+
+// RegionClient is the Object Storage client of one region for RunRegions
+type RegionClient struct {
+	Region string
+	Client ObjectStorageAPI
+}
+
+// RunRegions runs Run once per region, in order, with the region's client.
+// Each region downloads into a subfolder of config.DownloadFolder named after
+// it and writes its own operation report, state file, cost summary and
+// objects JSON with a _<region> suffix. The results are then merged into
+// config.ReportFile, with paths relative to config.DownloadFolder, and into a
+// single summary CSV with a region column. A failed region does not stop the
+// others; the errors are returned joined. Without such errors, files that
+// failed in any region are returned as one *DownloadErrors. config must not
+// use CompareOnly, VerifyOnly, ObjectList, ResumeReport or MetricsAddr.
+func RunRegions(ctx context.Context, config Config, clients []RegionClient) (Stats, []OperationResult, error) {
+	start := time.Now()
+	var reports []Report
+	var results []OperationResult
+	var errs, fileErrs []error
+	listed, failed := 0, 0
+	for _, rc := range clients {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "Region %s\n", rc.Region)
+		sub := config
+		sub.Region = rc.Region
+		sub.ReportFile = suffixedPath(config.ReportFile, rc.Region)
+		if config.DownloadFolder != "" {
+			sub.DownloadFolder = filepath.Join(config.DownloadFolder, rc.Region)
+		}
+		if config.StateFile != "" {
+			sub.StateFile = suffixedPath(config.StateFile, rc.Region)
+		}
+		if config.CostSummary != "" {
+			sub.CostSummary = suffixedPath(config.CostSummary, rc.Region)
+		}
+		if config.ObjectsJSON != "" {
+			sub.ObjectsJSON = suffixedPath(config.ObjectsJSON, rc.Region)
+		}
+		if config.Dates != nil {
+			sub.Dates = NewDateIndex(config.Dates.key)
+		}
+		sub.SummaryFile = ""
+		if config.SummaryFile != "" {
+			sub.summaryRows = &reports
+		}
+
+		stats, regionResults, err := Run(ctx, sub, rc.Client)
+		listed += stats.Listed
+		for _, r := range regionResults {
+			if r.RelativePath != "" {
+				r.RelativePath = path.Join(rc.Region, r.RelativePath)
+			}
+			results = append(results, r)
+		}
+		var failures *DownloadErrors
+		if errors.As(err, &failures) {
+			fileErrs = append(fileErrs, fmt.Errorf("region %s: %w", rc.Region, failures.Err))
+			failed += failures.Failed
+		} else if err != nil {
+			slog.Error("Region failed", "region", rc.Region, "error", err)
+			errs = append(errs, fmt.Errorf("region %s: %w", rc.Region, err))
+		}
+	}
+
+	if config.DryRun || config.DownloadFolder != "" {
+		if err := writeReport(results, config.ReportFile, config.ReportFormat); err != nil {
+			errs = append(errs, fmt.Errorf("writing operation report %s: %w", config.ReportFile, err))
+		} else {
+			fmt.Fprintf(os.Stderr, "Operation report of all regions generated: %s\n", config.ReportFile)
+		}
+	}
+	if config.SummaryFile != "" && ctx.Err() == nil {
+		if err := writeSummaries(reports, config, ""); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	stats := computeStats(listed, results, time.Since(start))
+	if config.DryRun || config.DownloadFolder != "" {
+		if err := writeStatsFile(stats, statsFilePath(config.ReportFile)); err != nil {
+			slog.Warn("Could not write stats file", "path", statsFilePath(config.ReportFile), "error", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return stats, results, ctx.Err()
+	}
+	if len(errs) > 0 {
+		return stats, results, errors.Join(errs...)
+	}
+	if len(fileErrs) == 0 {
+		return stats, results, nil
+	}
+	return stats, results, &DownloadErrors{Failed: failed, Err: errors.Join(fileErrs...)}
+}
@@ -0,0 +1,304 @@
+package focusreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// fakeServiceError is a common.ServiceError with a chosen status code
+type fakeServiceError struct {
+	status int
+	code   string
+}
+
+func (e fakeServiceError) Error() string {
+	return fmt.Sprintf("fake service error %d %s", e.status, e.code)
+}
+func (e fakeServiceError) GetHTTPStatusCode() int  { return e.status }
+func (e fakeServiceError) GetMessage() string      { return e.code }
+func (e fakeServiceError) GetCode() string         { return e.code }
+func (e fakeServiceError) GetOpcRequestID() string { return "fake-request" }
+
+// fakeClient is an in-memory ObjectStorageAPI. ListObjects pages through the
+// objects in name order like Object Storage does, and the counters record the
+// calls each test can assert on.
+type fakeClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+
+	lists, heads, gets atomic.Int32
+
+	// Returns the NextStartWith of a page instead of the name of the next
+	// object, when set
+	nextStart func(page int, next *string) *string
+	// Fails a GetObject attempt (counted from 1 per object) when it returns
+	// an error; the *http.Response, if any, becomes the RawResponse
+	getErr   func(name string, attempt int) (*http.Response, error)
+	attempts map[string]int
+	// GetObject responses carry no Content, as some zero-byte objects do
+	nilContent bool
+	// GetObject answers a Range request with the whole object and a 200, as
+	// a server without range support does
+	ignoreRange bool
+}
+
+func newFakeClient(objects map[string]string) *fakeClient {
+	c := &fakeClient{objects: make(map[string][]byte), attempts: make(map[string]int)}
+	for name, data := range objects {
+		c.objects[name] = []byte(data)
+	}
+	return c
+}
+
+func fakeETag(data []byte) string {
+	return fmt.Sprintf("etag-%x", md5.Sum(data))
+}
+
+func (c *fakeClient) ListObjects(ctx context.Context, req objectstorage.ListObjectsRequest) (objectstorage.ListObjectsResponse, error) {
+	page := int(c.lists.Add(1))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.objects))
+	for name := range c.objects {
+		if req.Prefix != nil && !strings.HasPrefix(name, *req.Prefix) {
+			continue
+		}
+		if req.Start != nil && name < *req.Start {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limit := len(names)
+	if req.Limit != nil && *req.Limit < limit {
+		limit = *req.Limit
+	}
+	var resp objectstorage.ListObjectsResponse
+	for _, name := range names[:limit] {
+		data := c.objects[name]
+		resp.ListObjects.Objects = append(resp.ListObjects.Objects, objectstorage.ObjectSummary{
+			Name: common.String(name),
+			Size: common.Int64(int64(len(data))),
+			Etag: common.String(fakeETag(data)),
+		})
+	}
+	var next *string
+	if limit < len(names) {
+		next = common.String(names[limit])
+	}
+	if c.nextStart != nil {
+		next = c.nextStart(page, next)
+	}
+	resp.ListObjects.NextStartWith = next
+	return resp, nil
+}
+
+func (c *fakeClient) HeadObject(ctx context.Context, req objectstorage.HeadObjectRequest) (objectstorage.HeadObjectResponse, error) {
+	c.heads.Add(1)
+	c.mu.Lock()
+	data, ok := c.objects[*req.ObjectName]
+	c.mu.Unlock()
+	if !ok {
+		return objectstorage.HeadObjectResponse{}, fakeServiceError{status: 404, code: "ObjectNotFound"}
+	}
+	return objectstorage.HeadObjectResponse{
+		ContentLength: common.Int64(int64(len(data))),
+		ETag:          common.String(fakeETag(data)),
+	}, nil
+}
+
+func (c *fakeClient) GetObject(ctx context.Context, req objectstorage.GetObjectRequest) (objectstorage.GetObjectResponse, error) {
+	c.gets.Add(1)
+	c.mu.Lock()
+	data, ok := c.objects[*req.ObjectName]
+	c.attempts[*req.ObjectName]++
+	attempt := c.attempts[*req.ObjectName]
+	c.mu.Unlock()
+	if !ok {
+		return objectstorage.GetObjectResponse{}, fakeServiceError{status: 404, code: "ObjectNotFound"}
+	}
+	if c.getErr != nil {
+		if raw, err := c.getErr(*req.ObjectName, attempt); err != nil {
+			return objectstorage.GetObjectResponse{RawResponse: raw}, err
+		}
+	}
+
+	body := data
+	ranged := req.Range != nil && !c.ignoreRange
+	if ranged {
+		offset, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(*req.Range, "bytes="), "-"))
+		if err != nil || offset > len(data) {
+			return objectstorage.GetObjectResponse{}, fakeServiceError{status: 416, code: "InvalidRange"}
+		}
+		body = data[offset:]
+	}
+	resp := objectstorage.GetObjectResponse{
+		Content:       io.NopCloser(bytes.NewReader(body)),
+		ContentLength: common.Int64(int64(len(body))),
+		ETag:          common.String(fakeETag(data)),
+	}
+	if ranged {
+		resp.RawResponse = &http.Response{StatusCode: http.StatusPartialContent}
+		resp.ContentRange = common.String(fmt.Sprintf("bytes %d-%d/%d", len(data)-len(body), len(data)-1, len(data)))
+	}
+	if c.nilContent {
+		resp.Content = nil
+	}
+	return resp, nil
+}
+
+func (c *fakeClient) RestoreObjects(ctx context.Context, req objectstorage.RestoreObjectsRequest) (objectstorage.RestoreObjectsResponse, error) {
+	return objectstorage.RestoreObjectsResponse{}, nil
+}
+
+// testConfig is the Config main builds from the default flags, writing
+// under a temporary directory
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	dir := t.TempDir()
+	return Config{
+		MaxWorkers:     4,
+		DownloadFolder: dir,
+		ReportFile:     filepath.Join(dir, "report.csv"),
+		Retry:          RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+		Namespace:      "ns",
+		BucketName:     "bucket",
+		Quiet:          true,
+		NamePattern:    regexp.MustCompile(DefaultNamePattern),
+	}
+}
+
+func objectNames(objects []objectstorage.ObjectSummary) []string {
+	names := make([]string, len(objects))
+	for i, obj := range objects {
+		names[i] = *obj.Name
+	}
+	return names
+}
+
+func TestRunDownloadsReports(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "first report",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "second report",
+	})
+	config := testConfig(t)
+
+	stats, results, err := Run(context.Background(), config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Downloaded != 2 || stats.Failed != 0 || len(results) != 2 {
+		t.Fatalf("stats %+v with %d results, want 2 downloaded", stats, len(results))
+	}
+	for file, want := range map[string]string{
+		"20240315_0001.csv.gz": "first report",
+		"20240316_0001.csv.gz": "second report",
+	} {
+		data, err := os.ReadFile(filepath.Join(config.DownloadFolder, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, want %q", file, data, want)
+		}
+	}
+	if _, err := os.Stat(config.ReportFile); err != nil {
+		t.Errorf("operation report not written: %v", err)
+	}
+}
+
+func TestRunSizeLimits(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/14/0001.csv.gz": "abcd",
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "abcde",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "abcdefghij",
+		"FOCUS Reports/2024/03/17/0001.csv.gz": "abcdefghijk",
+	})
+	config := testConfig(t)
+	config.MinSize, config.MaxSize = 5, 10
+	config.SummaryFile = filepath.Join(t.TempDir(), "summary.csv")
+
+	stats, _, err := Run(context.Background(), config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Downloaded != 2 {
+		t.Errorf("downloaded %d files, want 2", stats.Downloaded)
+	}
+	data, err := os.ReadFile(config.SummaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := strings.Count(strings.TrimSpace(string(data)), "\n"); rows != 2 {
+		t.Errorf("summary CSV has %d rows, want 2:\n%s", rows, data)
+	}
+}
+
+func TestRunObjectDeletedAfterListing(t *testing.T) {
+	deleted := "FOCUS Reports/2024/03/15/0001.csv.gz"
+	client := newFakeClient(map[string]string{deleted: "gone", "FOCUS Reports/2024/03/16/0001.csv.gz": "report"})
+	client.getErr = func(name string, attempt int) (*http.Response, error) {
+		if name == deleted {
+			return &http.Response{StatusCode: http.StatusNotFound}, fakeServiceError{status: http.StatusNotFound, code: "ObjectNotFound"}
+		}
+		return nil, nil
+	}
+	config := testConfig(t)
+
+	stats, results, err := Run(context.Background(), config, client)
+	if !isNotFound(err) {
+		t.Errorf("Run returned %v, want the 404 of the deleted object", err)
+	}
+	if stats.Downloaded != 1 {
+		t.Errorf("downloaded %d files, want 1", stats.Downloaded)
+	}
+	for _, r := range results {
+		if r.FileName == "20240315_0001.csv.gz" && (r.Status != "Not found" || r.Attempts != 1) {
+			t.Errorf("deleted object has status %q after %d attempts, want Not found after 1", r.Status, r.Attempts)
+		}
+	}
+	if gets := client.gets.Load(); gets != 2 {
+		t.Errorf("made %d GETs, want 2: a 404 is not retried", gets)
+	}
+}
+
+func TestRunHeadsEachObjectOnce(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "first report",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "second report",
+	})
+	config := testConfig(t)
+	if _, _, err := Run(context.Background(), config, client); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rerun's skip check and summary both need the metadata
+	client.heads.Store(0)
+	config.SummaryFile = filepath.Join(t.TempDir(), "summary.csv")
+	config.ForceHead = true
+	if _, _, err := Run(context.Background(), config, client); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.heads.Load(); n != 2 {
+		t.Errorf("made %d HEADs for two objects, want 2", n)
+	}
+}
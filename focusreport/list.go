@@ -0,0 +1,426 @@
+package focusreport
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// DefaultNamePattern matches the FOCUS export naming convention, e.g.
+// "FOCUS Reports/2024/03/15/...", ".../FOCUS/2024/..." or "FOCUS_REPORT_...",
+// without catching unrelated names such as "NOT_FOCUSED_data"
+const DefaultNamePattern = `(?:^|/)FOCUS(?:[ _](?:Reports?|REPORTS?))?(?:/|_|-|\.)`
+
+var defaultFocusPattern = regexp.MustCompile(DefaultNamePattern)
+
+// matchesFocus reports whether an object name is a FOCUS report according to
+// pattern; a nil pattern falls back to DefaultNamePattern
+func matchesFocus(name string, pattern *regexp.Regexp) bool {
+	if pattern == nil {
+		pattern = defaultFocusPattern
+	}
+	return pattern.MatchString(name)
+}
+
+// matchesGlob matches name against a path.Match pattern. A pattern without
+// a "/" is matched against the base name, so "*_manifest.json" applies in
+// every folder.
+func matchesGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// matchesFilters applies the -include and -exclude globs to name. Exclude
+// wins over include; without include patterns every name is included.
+func matchesFilters(name string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matchesGlob(pattern, name) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matchesGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// listFields are the object attributes requested from ListObjects
+const listFields = "name,size,etag,md5,timeCreated,storageTier,archivalState"
+
+// MaxPageSize is the most objects ListObjects returns per page
+const MaxPageSize = 1000
+
+// maxListPages bounds a single listing, far beyond any FOCUS bucket even
+// with a small -page-size
+const maxListPages = 1000000
+
+// nextPageStart returns the Start of the page after the one requested with
+// start, whose response carried next, or nil when the listing is done. A
+// token equal to start would request the same page forever; such a token, a
+// blank one, or more than maxListPages pages end the listing with a warning.
+func nextPageStart(start, next *string, pages int, prefix string) *string {
+	if next == nil || strings.TrimSpace(*next) == "" {
+		if next != nil && *next != "" {
+			slog.Warn("Listing stopped at a blank NextStartWith", "prefix", prefix, "pages", pages)
+		}
+		return nil
+	}
+	if start != nil && *next == *start {
+		slog.Warn("Listing stopped: NextStartWith did not advance", "prefix", prefix, "start", *start, "next_start_with", *next, "pages", pages)
+		return nil
+	}
+	if pages >= maxListPages {
+		slog.Warn("Listing stopped after too many pages", "prefix", prefix, "pages", pages, "next_start_with", *next)
+		return nil
+	}
+	return next
+}
+
+// listFocusReports lists the FOCUS reports under each of config.Prefixes
+// concurrently, at most config.MaxWorkers listings at a time, and merges them
+// without duplicates, sorted by name as a single listing of the bucket would
+// be. With fewer than two prefixes it is listAllFocusReports. If some
+// listings fail, the objects gathered under every prefix are returned along
+// with the errors of the failed prefixes. The bool reports whether
+// config.MaxObjects left matching objects out.
+func listFocusReports(ctx context.Context, client ObjectStorageAPI, config Config, namespace, bucketName string) ([]objectstorage.ObjectSummary, bool, error) {
+	if len(config.Prefixes) < 2 {
+		return listAllFocusReports(ctx, client, config, namespace, bucketName)
+	}
+
+	lists := make([][]objectstorage.ObjectSummary, len(config.Prefixes))
+	truncated := make([]bool, len(config.Prefixes))
+	errs := make([]error, len(config.Prefixes))
+	sem := make(chan struct{}, config.MaxWorkers)
+	var wg sync.WaitGroup
+	for i, prefix := range config.Prefixes {
+		prefixConfig := config
+		prefixConfig.Prefix = prefix
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			lists[i], truncated[i], errs[i] = listAllFocusReports(ctx, client, prefixConfig, namespace, bucketName)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("prefix %q: %w", prefixConfig.Prefix, errs[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Overlapping prefixes list the same objects more than once
+	var merged []objectstorage.ObjectSummary
+	seen := make(map[string]bool)
+	for _, objects := range lists {
+		for _, obj := range objects {
+			if !seen[*obj.Name] {
+				seen[*obj.Name] = true
+				merged = append(merged, obj)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return *merged[i].Name < *merged[j].Name
+	})
+	capped := false
+	for _, t := range truncated {
+		capped = capped || t
+	}
+	if config.MaxObjects > 0 && len(merged) > config.MaxObjects {
+		merged = merged[:config.MaxObjects]
+		capped = true
+	}
+	return merged, capped, errors.Join(errs...)
+}
+
+// readObjectList reads the object names of -object-list: one per line or,
+// for a .csv file, the values of column. An empty column picks object_path,
+// then object_name, so a summary or comparison CSV can be fed back as is.
+// Surrounding spaces, blank lines and repeated names are dropped.
+func readObjectList(filename, column string) ([]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		reader := csv.NewReader(strings.NewReader(string(data)))
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		columns := []string{"object_path", "object_name"}
+		if column != "" {
+			columns = []string{column}
+		}
+		index := -1
+		for _, c := range columns {
+			for i, h := range records[0] {
+				if index < 0 && strings.TrimSpace(h) == c {
+					index = i
+				}
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("no %s column in the header", strings.Join(columns, " or "))
+		}
+		for _, record := range records[1:] {
+			if index < len(record) {
+				values = append(values, record[index])
+			}
+		}
+	} else {
+		values = strings.Split(string(data), "\n")
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		name := strings.TrimSpace(value)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// headObjects checks with HeadObject that each named object exists, at most
+// config.metadataWorkers() calls in flight. It returns the existing objects as
+// listing entries in the order given, and the names that do not exist.
+// Objects whose check failed otherwise are kept with their name only, so
+// their download reports the error.
+func headObjects(ctx context.Context, client ObjectStorageAPI, config Config, namespace, bucketName string, names []string) (objects []objectstorage.ObjectSummary, missing []string) {
+	found := make([]objectstorage.ObjectSummary, len(names))
+	notFound := make([]bool, len(names))
+	sem := make(chan struct{}, config.metadataWorkers())
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			obj := objectstorage.ObjectSummary{Name: common.String(name)}
+			meta, err := getObjectMetadata(ctx, client, config.Retry, namespace, bucketName, name)
+			switch {
+			case isNotFound(err):
+				notFound[i] = true
+			case err != nil:
+				slog.Warn("Could not check listed object", "object", name, "error", err)
+			default:
+				obj.Size = meta.ContentLength
+				obj.Etag = meta.ETag
+				obj.Md5 = meta.ContentMd5
+				obj.TimeModified = meta.LastModified
+				obj.StorageTier = objectstorage.StorageTierEnum(meta.StorageTier)
+				obj.ArchivalState = objectstorage.ArchivalStateEnum(meta.ArchivalState)
+			}
+			found[i] = obj
+		}(i, name)
+	}
+	wg.Wait()
+
+	for i, obj := range found {
+		if notFound[i] {
+			missing = append(missing, names[i])
+		} else {
+			objects = append(objects, obj)
+		}
+	}
+	return objects, missing
+}
+
+// listAllFocusReports lists all objects matching config.NamePattern and the
+// config.Include/Exclude globs, dated within [config.FromDate, config.ToDate].
+// A non-empty config.Prefix narrows
+// the listing server-side; NextStartWith pagination stays within the prefix.
+// Each page is retried per config.Retry; if a page still fails, the objects
+// gathered from earlier pages are returned together with the error. The bool
+// reports whether the listing stopped at config.MaxObjects with more matching
+// objects on the last page or a further page still to list.
+func listAllFocusReports(ctx context.Context, client ObjectStorageAPI, config Config, namespace, bucketName string) ([]objectstorage.ObjectSummary, bool, error) {
+	var allObjects []objectstorage.ObjectSummary
+	var nextStart *string
+	seen := make(map[string]bool)
+	pages := 0
+	pageSize := MaxPageSize
+	if config.PageSize > 0 {
+		pageSize = config.PageSize
+	}
+
+	for {
+		// Stop between pages as soon as the run is cancelled
+		if err := ctx.Err(); err != nil {
+			slog.Warn("Listing cancelled", "pages_succeeded", pages, "objects_collected", len(allObjects))
+			return allObjects, false, err
+		}
+
+		req := objectstorage.ListObjectsRequest{
+			NamespaceName: &namespace,
+			BucketName:    &bucketName,
+			Start:         nextStart,
+			Limit:         common.Int(pageSize),
+			Fields:        common.String(listFields),
+		}
+		if config.Prefix != "" {
+			req.Prefix = &config.Prefix
+		}
+
+		var resp objectstorage.ListObjectsResponse
+		_, err := withRetry(ctx, config.Retry, "ListObjects", func() error {
+			return callWithTimeout(ctx, config.Retry.RequestTimeout, func(ctx context.Context) error {
+				var err error
+				resp, err = client.ListObjects(ctx, req)
+				return withRetryAfter(err, resp.RawResponse)
+			})
+		})
+		if err != nil {
+			slog.Error("Listing failed", "pages_succeeded", pages, "objects_collected", len(allObjects), "error", err)
+			return allObjects, false, fmt.Errorf("error listing objects (page %d): %w", pages+1, err)
+		}
+		pages++
+
+		var candidates []objectstorage.ObjectSummary
+		var names []string
+		for _, obj := range resp.ListObjects.Objects {
+			if obj.Name == nil {
+				continue
+			}
+			name := *obj.Name
+			if seen[name] || !matchesFocus(name, config.NamePattern) || !matchesFilters(name, config.Include, config.Exclude) {
+				continue
+			}
+			candidates = append(candidates, obj)
+			names = append(names, name)
+		}
+		if config.Dates != nil {
+			config.Dates.Resolve(ctx, client, config.Retry, config.metadataWorkers(), namespace, bucketName, names)
+		}
+
+		truncated := false
+		for _, obj := range candidates {
+			name := *obj.Name
+			objDate, _, err := reportDate(name, config)
+			if err != nil {
+				slog.Warn("Skipping object with invalid date format", "object", name)
+				continue
+			}
+			if !dateInRange(objDate, config.FromDate, config.ToDate) {
+				continue
+			}
+			if config.MaxObjects > 0 && len(allObjects) >= config.MaxObjects {
+				truncated = true
+				break
+			}
+			seen[name] = true
+			allObjects = append(allObjects, obj)
+		}
+
+		next := nextPageStart(nextStart, resp.ListObjects.NextStartWith, pages, config.Prefix)
+		if config.MaxObjects > 0 && len(allObjects) >= config.MaxObjects {
+			// Exactly MaxObjects matching objects is not a truncation
+			if truncated = truncated || next != nil; truncated {
+				slog.Warn("Listing truncated by -max-objects", "max_objects", config.MaxObjects, "pages", pages)
+			}
+			return allObjects, truncated, nil
+		}
+		if nextStart = next; nextStart == nil {
+			break
+		}
+	}
+
+	return allObjects, false, nil
+}
+
+// listPrefixes returns the "directories" directly under prefix, as reported by
+// ListObjects with a "/" delimiter, following pagination
+func listPrefixes(ctx context.Context, client ObjectStorageAPI, retry RetryConfig, namespace, bucketName, prefix string) ([]string, error) {
+	var prefixes []string
+	var nextStart *string
+	pages := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return prefixes, err
+		}
+		req := objectstorage.ListObjectsRequest{
+			NamespaceName: &namespace,
+			BucketName:    &bucketName,
+			Delimiter:     common.String("/"),
+			Start:         nextStart,
+			Limit:         common.Int(MaxPageSize),
+			Fields:        common.String("name"),
+		}
+		if prefix != "" {
+			req.Prefix = &prefix
+		}
+
+		var resp objectstorage.ListObjectsResponse
+		_, err := withRetry(ctx, retry, "ListObjects "+prefix, func() error {
+			return callWithTimeout(ctx, retry.RequestTimeout, func(ctx context.Context) error {
+				var err error
+				resp, err = client.ListObjects(ctx, req)
+				return withRetryAfter(err, resp.RawResponse)
+			})
+		})
+		if err != nil {
+			return prefixes, err
+		}
+		prefixes = append(prefixes, resp.ListObjects.Prefixes...)
+		pages++
+
+		if nextStart = nextPageStart(nextStart, resp.ListObjects.NextStartWith, pages, prefix); nextStart == nil {
+			break
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+// PrintPrefixTree prints the prefixes under prefix to stdout, one per line and
+// indented by level, descending at most depth levels. Each level costs one
+// listing per prefix, so no objects are listed.
+func PrintPrefixTree(ctx context.Context, client ObjectStorageAPI, retry RetryConfig, namespace, bucketName, prefix string, depth, level int) error {
+	if depth <= 0 {
+		return nil
+	}
+	children, err := listPrefixes(ctx, client, retry, namespace, bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		fmt.Printf("%s%s\n", strings.Repeat("  ", level), strings.TrimPrefix(child, prefix))
+		if err := PrintPrefixTree(ctx, client, retry, namespace, bucketName, child, depth-1, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package focusreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+func TestListAllFocusReportsFiltersByDate(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/14/0001.csv.gz": "a",
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "b",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "c",
+		"FOCUS Reports/2024/03/17/0001.csv.gz": "d",
+		"reports/2024/03/15/other.csv.gz":      "e",
+	})
+	config := testConfig(t)
+	config.PageSize = 2
+	config.FromDate = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	config.ToDate = time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+
+	objects, _, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"FOCUS Reports/2024/03/15/0001.csv.gz", "FOCUS Reports/2024/03/16/0001.csv.gz"}
+	if got := objectNames(objects); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+	if n := client.lists.Load(); n != 3 {
+		t.Errorf("listed %d pages of two objects, want 3", n)
+	}
+}
+
+func TestMatchesFocus(t *testing.T) {
+	for name, want := range map[string]bool{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": true,
+		"FOCUS Report/2024/03/15/0001.csv.gz":  true,
+		"exports/FOCUS/2024/03/15/part-0.csv":  true,
+		"FOCUS_REPORT_20240315.csv.gz":         true,
+		"FOCUS-20240315.csv":                   true,
+		"NOT_FOCUSED_data":                     false,
+		"reports/UNFOCUS/2024/03/15/a.csv":     false,
+		"FOCUSED/2024/03/15/a.csv":             false,
+		"focus/2024/03/15/a.csv":               false,
+		"cost/2024/03/15/a.csv":                false,
+	} {
+		if got := matchesFocus(name, nil); got != want {
+			t.Errorf("matchesFocus(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestListAllFocusReportsNoDuplicates(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS_REPORT/2024/03/15/0001.csv.gz": "a",
+		"FOCUS/2024/03/15/0001.csv.gz":        "b",
+		"NOT_FOCUSED_data/2024/03/15/x.csv":   "c",
+	})
+	// The second page starts over at the object the first one returned
+	client.nextStart = func(page int, next *string) *string {
+		if next != nil && page == 1 {
+			return common.String("FOCUS/2024/03/15/0001.csv.gz")
+		}
+		return next
+	}
+	config := testConfig(t)
+	config.PageSize = 1
+
+	objects, _, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"FOCUS/2024/03/15/0001.csv.gz", "FOCUS_REPORT/2024/03/15/0001.csv.gz"}
+	if got := objectNames(objects); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+}
+
+func TestListAllFocusReportsCancelled(t *testing.T) {
+	objects := make(map[string]string)
+	for day := 1; day <= 20; day++ {
+		objects[fmt.Sprintf("FOCUS Reports/2024/03/%02d/0001.csv.gz", day)] = "report"
+	}
+	client := newFakeClient(objects)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.nextStart = func(page int, next *string) *string {
+		if page == 2 {
+			cancel()
+		}
+		return next
+	}
+	config := testConfig(t)
+	config.PageSize = 2
+
+	got, _, err := listAllFocusReports(ctx, client, config, "ns", "bucket")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("listing returned %v, want context.Canceled", err)
+	}
+	if n := client.lists.Load(); n != 2 {
+		t.Errorf("listed %d pages after cancelling on the second, want 2", n)
+	}
+	if len(got) != 4 {
+		t.Errorf("returned %d objects from the pages before cancelling, want 4", len(got))
+	}
+}
+
+func TestListAllFocusReportsPages(t *testing.T) {
+	objects := map[string]string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "a",
+		"FOCUS_REPORT/2024/03/15/0001.csv.gz":  "b",
+		"NOT_FOCUSED_data/2024/03/15/x.csv":    "c",
+		"exports/FOCUS/2024/03/15/part-0.csv":  "d",
+		"exports/cost/2024/03/15/part-0.csv":   "e",
+		"exports/cost/2024/03/16/part-0.csv":   "f",
+		"reports/2024/03/15/other.csv.gz":      "g",
+		"reports/FOCUS/2024/03/16/0001.csv.gz": "h",
+		"reports/UNFOCUS/2024/03/16/x.csv":     "i",
+	}
+	want := []string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz",
+		"FOCUS_REPORT/2024/03/15/0001.csv.gz",
+		"exports/FOCUS/2024/03/15/part-0.csv",
+		"reports/FOCUS/2024/03/16/0001.csv.gz",
+	}
+	for _, tc := range []struct {
+		name string
+		last *string // NextStartWith of the final page
+	}{
+		{"nil next start", nil},
+		{"empty next start", common.String("")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient(objects)
+			client.nextStart = func(page int, next *string) *string {
+				if next == nil {
+					return tc.last
+				}
+				return next
+			}
+			config := testConfig(t)
+			config.PageSize = 3
+
+			got, _, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := objectNames(got); strings.Join(names, ",") != strings.Join(want, ",") {
+				t.Errorf("listed %q, want %q", names, want)
+			}
+			if n := client.lists.Load(); n != 3 {
+				t.Errorf("listed %d pages, want 3", n)
+			}
+		})
+	}
+}
+
+func TestMatchesFilters(t *testing.T) {
+	const (
+		compute  = "FOCUS Reports/2024/03/15/compute_0001.csv.gz"
+		storage  = "FOCUS Reports/2024/03/15/storage_0001.csv.gz"
+		manifest = "FOCUS Reports/2024/03/15/compute_manifest.json"
+	)
+	for _, tc := range []struct {
+		name             string
+		include, exclude []string
+		want             []string // of compute, storage and manifest
+	}{
+		{"no filters", nil, nil, []string{compute, storage, manifest}},
+		{"include", []string{"*compute*"}, nil, []string{compute, manifest}},
+		{"exclude", nil, []string{"*_manifest.json"}, []string{compute, storage}},
+		{"exclude wins over include", []string{"*compute*"}, []string{"*_manifest.json"}, []string{compute}},
+		{"several includes", []string{"compute_*.csv.gz", "storage_*"}, nil, []string{compute, storage}},
+		{"exclude everything included", []string{"*compute*"}, []string{"compute*"}, nil},
+		{"full path pattern", []string{"FOCUS Reports/2024/03/*/storage_*"}, nil, []string{storage}},
+		{"full path pattern must match the whole name", []string{"2024/03/15/*"}, nil, nil},
+	} {
+		var got []string
+		for _, name := range []string{compute, storage, manifest} {
+			if matchesFilters(name, tc.include, tc.exclude) {
+				got = append(got, name)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: kept %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNextPageStart(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		start, next *string
+		pages       int
+		want        *string
+	}{
+		{"last page", nil, nil, 1, nil},
+		{"empty token", nil, common.String(""), 1, nil},
+		{"whitespace token", common.String("a"), common.String(" \t"), 2, nil},
+		{"stuck token", common.String("b"), common.String("b"), 2, nil},
+		{"first page", nil, common.String("b"), 1, common.String("b")},
+		{"advancing token", common.String("b"), common.String("c"), 2, common.String("c")},
+		{"too many pages", common.String("b"), common.String("c"), maxListPages, nil},
+	} {
+		got := nextPageStart(tc.start, tc.next, tc.pages, "")
+		if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+			t.Errorf("%s: nextPageStart = %v, want %v", tc.name, stringValue(got), stringValue(tc.want))
+		}
+	}
+}
+
+func TestListAllFocusReportsStuckToken(t *testing.T) {
+	client := newFakeClient(map[string]string{
+		"FOCUS Reports/2024/03/15/0001.csv.gz": "a",
+		"FOCUS Reports/2024/03/16/0001.csv.gz": "b",
+		"FOCUS Reports/2024/03/17/0001.csv.gz": "c",
+	})
+	// Every page points back at the second object
+	client.nextStart = func(page int, next *string) *string {
+		return common.String("FOCUS Reports/2024/03/16/0001.csv.gz")
+	}
+	config := testConfig(t)
+	config.PageSize = 1
+
+	done := make(chan []objectstorage.ObjectSummary)
+	go func() {
+		objects, _, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+		if err != nil {
+			t.Error(err)
+		}
+		done <- objects
+	}()
+	select {
+	case objects := <-done:
+		if len(objects) != 2 || client.lists.Load() != 2 {
+			t.Errorf("listed %d objects over %d pages, want 2 over 2", len(objects), client.lists.Load())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("listing did not stop at a stuck NextStartWith")
+	}
+}
+
+func TestListAllFocusReportsMaxObjects(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		days, pageSize int
+		outOfRange     bool // add a later object outside the date range
+		listed         int
+		truncated      bool
+	}{
+		{"exactly max objects", 3, 0, false, 3, false},
+		{"exactly max objects and an unmatched one", 3, 0, true, 3, false},
+		{"one more on the page", 4, 0, false, 3, true},
+		{"more on the next page", 6, 3, false, 3, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := make(map[string]string)
+			for day := 1; day <= tc.days; day++ {
+				objects[fmt.Sprintf("FOCUS Reports/2024/03/%02d/0001.csv.gz", day)] = "report"
+			}
+			if tc.outOfRange {
+				objects["FOCUS Reports/2024/04/01/0001.csv.gz"] = "report"
+			}
+			client := newFakeClient(objects)
+			config := testConfig(t)
+			config.MaxObjects = 3
+			config.PageSize = tc.pageSize
+			config.ToDate = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+			got, truncated, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tc.listed || truncated != tc.truncated {
+				t.Errorf("listed %d objects, truncated %v; want %d, %v", len(got), truncated, tc.listed, tc.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package focusreport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ManifestEntry records what was downloaded for one object
+type ManifestEntry struct {
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	ReportDate   string    `json:"report_date,omitempty"` // YYYY-MM-DD
+}
+
+// IdentityEntry caches the namespace, bucket and tenancy resolved for an OCI profile
+type IdentityEntry struct {
+	Namespace  string    `json:"namespace"`
+	BucketName string    `json:"bucket_name"`
+	TenancyID  string    `json:"tenancy_id,omitempty"` // authenticated tenancy, empty in older state files
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// Manifest is the state file mapping object names to their last download,
+// used to skip objects whose remote ETag has not changed. It also caches the
+// resolved namespace and bucket per OCI profile.
+type Manifest struct {
+	mu         sync.Mutex
+	path       string
+	Objects    map[string]ManifestEntry `json:"objects"`
+	Identities map[string]IdentityEntry `json:"identities,omitempty"`
+}
+
+// LoadManifest reads the state file at path; a missing file yields an empty manifest
+func LoadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, Objects: make(map[string]ManifestEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if m.Objects == nil {
+		m.Objects = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+// Unchanged reports whether objectName was downloaded before with the given ETag
+func (m *Manifest) Unchanged(objectName, etag string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Objects[objectName]
+	return ok && etag != "" && entry.ETag == etag
+}
+
+// ETag returns the ETag objectName was last downloaded with
+func (m *Manifest) ETag(objectName string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Objects[objectName]
+	return entry.ETag, ok && entry.ETag != ""
+}
+
+// Record stores a successful download and persists the manifest
+func (m *Manifest) Record(objectName string, entry ManifestEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Objects[objectName] = entry
+	return m.save()
+}
+
+// LatestReportDate returns the most recent report date among the recorded
+// downloads; entries without a valid date are ignored
+func (m *Manifest) LatestReportDate() (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var latest time.Time
+	for _, entry := range m.Objects {
+		date, err := time.Parse("2006-01-02", entry.ReportDate)
+		if err == nil && date.After(latest) {
+			latest = date
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// Identity returns the cached namespace and bucket for profile
+func (m *Manifest) Identity(profile string) (IdentityEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.Identities[profile]
+	return entry, ok && entry.Namespace != "" && entry.BucketName != ""
+}
+
+// RecordIdentity caches the namespace and bucket for profile and persists the manifest
+func (m *Manifest) RecordIdentity(profile string, entry IdentityEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Identities == nil {
+		m.Identities = make(map[string]IdentityEntry)
+	}
+	m.Identities[profile] = entry
+	return m.save()
+}
+
+// save writes the manifest atomically; the caller must hold m.mu
+func (m *Manifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, m.path)
+}
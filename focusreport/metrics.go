@@ -0,0 +1,98 @@
+package focusreport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus series exported with -metrics-addr. A nil
+// *metrics records nothing, so the worker path costs nothing without it.
+type metrics struct {
+	registry *prometheus.Registry
+	files    prometheus.Counter
+	bytes    prometheus.Counter
+	failures *prometheus.CounterVec
+	retries  prometheus.Counter
+	duration prometheus.Histogram
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		files: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "focus_downloaded_files_total",
+			Help: "Files downloaded successfully.",
+		}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "focus_downloaded_bytes_total",
+			Help: "Bytes written to disk by successful downloads.",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "focus_download_failures_total",
+			Help: "Failed downloads by reason.",
+		}, []string{"reason"}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "focus_download_retries_total",
+			Help: "GetObject attempts beyond the first.",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "focus_download_duration_seconds",
+			Help:    "Time spent on each downloaded file, including retries.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		}),
+	}
+	m.registry.MustRegister(m.files, m.bytes, m.failures, m.retries, m.duration)
+	return m
+}
+
+// observe records the outcome of one download job
+func (m *metrics) observe(result OperationResult, err error, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	if result.Attempts > 1 {
+		m.retries.Add(float64(result.Attempts - 1))
+	}
+	switch {
+	case err != nil:
+		m.failures.WithLabelValues(strings.ReplaceAll(strings.ToLower(result.Status), " ", "_")).Inc()
+	case result.Downloaded:
+		m.files.Inc()
+		m.bytes.Add(float64(result.FileSize))
+		m.duration.Observe(elapsed.Seconds())
+	}
+}
+
+// serveMetrics exposes m on addr under /metrics until ctx is done. The
+// listener is opened before returning so a bad address fails the run early.
+func serveMetrics(ctx context.Context, addr string, m *metrics) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("Serving metrics", "addr", ln.Addr().String(), "path", "/metrics")
+	return nil
+}
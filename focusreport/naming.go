@@ -0,0 +1,258 @@
+package focusreport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// DefaultFilenameTemplate is the flat layout naming, YYYYMMDD_name
+const DefaultFilenameTemplate = "{date:20060102}_{name}"
+
+// templatePart is a literal or a placeholder of a filename template
+type templatePart struct {
+	literal string
+	field   string // "", "name" or "date"
+	format  string // time layout for "date"
+}
+
+// FilenameTemplate names downloaded files in the flat layout
+type FilenameTemplate []templatePart
+
+// ParseFilenameTemplate parses a -filename-template value. Placeholders are
+// {name} (the object's base name), {date} (YYYYMMDD) and {date:layout} with a
+// Go time layout. {name} is required, since without it every report of a day
+// would get the same file name.
+func ParseFilenameTemplate(s string) (FilenameTemplate, error) {
+	var tmpl FilenameTemplate
+	hasName := false
+	for s != "" {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			tmpl = append(tmpl, templatePart{literal: s})
+			break
+		}
+		if open > 0 {
+			tmpl = append(tmpl, templatePart{literal: s[:open]})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in filename template")
+		}
+		field, format, _ := strings.Cut(s[open+1:open+end], ":")
+		switch {
+		case field == "name" && format == "":
+			hasName = true
+		case field == "date":
+			if format == "" {
+				format = "20060102"
+			}
+		default:
+			return nil, fmt.Errorf("unknown placeholder %s in filename template", s[open:open+end+1])
+		}
+		tmpl = append(tmpl, templatePart{field: field, format: format})
+		s = s[open+end+1:]
+	}
+	if !hasName {
+		return nil, fmt.Errorf("filename template must contain {name}, otherwise reports of the same day collide")
+	}
+	return tmpl, nil
+}
+
+// HasDate reports whether the template includes a date placeholder
+func (t FilenameTemplate) HasDate() bool {
+	for _, part := range t {
+		if part.field == "date" {
+			return true
+		}
+	}
+	return false
+}
+
+// render builds the file name for baseName; an unknown date renders as unknown_date
+func (t FilenameTemplate) render(baseName string, date time.Time, dateOK bool) string {
+	var b strings.Builder
+	for _, part := range t {
+		switch part.field {
+		case "name":
+			b.WriteString(baseName)
+		case "date":
+			if dateOK {
+				b.WriteString(date.Format(part.format))
+			} else {
+				b.WriteString("unknown_date")
+			}
+		default:
+			b.WriteString(part.literal)
+		}
+	}
+	return b.String()
+}
+
+// invalidFileNameChars cannot appear in Windows file names
+const invalidFileNameChars = `<>:"/\|?*`
+
+// windowsReservedNames are device names Windows refuses as file names, with
+// or without an extension
+var windowsReservedNames = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[1-9]|LPT[1-9])(\.|$)`)
+
+// SanitizeFileName makes name valid on Windows: characters in
+// invalidFileNameChars and control characters become replacement, as do
+// trailing dots and spaces, and reserved device names get replacement in
+// front. An empty replacement returns name unchanged.
+func SanitizeFileName(name, replacement string) string {
+	if replacement == "" {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(invalidFileNameChars, r) {
+			b.WriteString(replacement)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	sanitized := b.String()
+	if trimmed := strings.TrimRight(sanitized, ". "); trimmed != sanitized {
+		sanitized = trimmed + strings.Repeat(replacement, len(sanitized)-len(trimmed))
+	}
+	if windowsReservedNames.MatchString(sanitized) {
+		sanitized = replacement + sanitized
+	}
+	return sanitized
+}
+
+// targetPath returns the slash-separated output path of an object relative to
+// the download folder, its report date, and whether it will be gunzipped on
+// download. The flat layout names the file with config.NameTemplate
+// (YYYYMMDD_name by default); the partitioned layout places it under YYYY/MM/DD/.
+// The file name is passed through SanitizeFileName with config.Sanitize.
+func targetPath(objectName string, config Config) (string, string, bool) {
+	date, _, err := reportDate(objectName, config)
+	day := "unknown"
+	if err == nil {
+		day = date.Format("2006-01-02")
+	}
+
+	// Drop .gz when decompressing
+	baseName := path.Base(objectName)
+	decompress := config.Decompress && strings.HasSuffix(baseName, ".gz")
+	if decompress {
+		baseName = strings.TrimSuffix(baseName, ".gz")
+	}
+	baseName = SanitizeFileName(baseName, config.Sanitize)
+
+	// Renames hold whole relative paths, for either layout
+	if renamed, ok := config.Renames[objectName]; ok {
+		return renamed, day, decompress
+	}
+
+	if config.Layout == "partitioned" {
+		dir := "unknown_date"
+		if err == nil {
+			dir = date.Format("2006/01/02")
+		}
+		return path.Join(dir, baseName), day, decompress
+	}
+
+	tmpl := config.NameTemplate
+	if tmpl == nil {
+		tmpl, _ = ParseFilenameTemplate(DefaultFilenameTemplate)
+	}
+	return tmpl.render(baseName, date, err == nil), day, decompress
+}
+
+// findCollisions returns the target paths that more than one object maps
+// to, with the names of those objects in listing order
+func findCollisions(objects []objectstorage.ObjectSummary, config Config) map[string][]string {
+	byPath := make(map[string][]string)
+	for _, obj := range objects {
+		if obj.Name == nil {
+			continue
+		}
+		relPath, _, _ := targetPath(*obj.Name, config)
+		byPath[relPath] = append(byPath[relPath], *obj.Name)
+	}
+	for relPath, names := range byPath {
+		if len(names) < 2 {
+			delete(byPath, relPath)
+		}
+	}
+	return byPath
+}
+
+// disambiguate inserts a short hash of the full object name before the
+// extensions of relPath, e.g. 20240315_0001.csv.gz becomes
+// 20240315_0001_1a2b3c4d.csv.gz. Every object of a collision is renamed so
+// the result does not depend on the listing order.
+func disambiguate(relPath, objectName string) string {
+	sum := sha256.Sum256([]byte(objectName))
+	dir, base := path.Split(relPath)
+	stem, ext := base, ""
+	if i := strings.Index(base[1:], "."); i >= 0 {
+		stem, ext = base[:i+1], base[i+1:]
+	}
+	return dir + stem + "_" + hex.EncodeToString(sum[:4]) + ext
+}
+
+// etagSidecarPath is where -etag-sidecars keeps the ETag of the object
+// downloaded to filePath
+func etagSidecarPath(filePath string) string {
+	return filePath + ".etag"
+}
+
+// readETagSidecar returns the ETag recorded next to filePath, or "" if there
+// is none
+func readETagSidecar(filePath string) string {
+	data, err := os.ReadFile(etagSidecarPath(filePath))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// contentTypeExtensions are the extensions -infer-extension appends, by
+// media type
+var contentTypeExtensions = map[string]string{
+	"text/csv":         ".csv",
+	"application/csv":  ".csv",
+	"application/json": ".json",
+	"text/json":        ".json",
+}
+
+// extensionForContentType returns the extension for a Content-Type header, or
+// "" if it is not CSV or JSON
+func extensionForContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return contentTypeExtensions[mediaType]
+}
+
+// localPath returns the file an object is stored in. That is filePath, unless
+// infer is set, filePath has no extension and only a copy with an inferred
+// extension exists from an earlier run.
+func localPath(filePath string, infer bool) string {
+	if !infer || filepath.Ext(filePath) != "" {
+		return filePath
+	}
+	if _, err := os.Stat(filePath); err == nil {
+		return filePath
+	}
+	for _, ext := range []string{".csv", ".json"} {
+		if _, err := os.Stat(filePath + ext); err == nil {
+			return filePath + ext
+		}
+	}
+	return filePath
+}
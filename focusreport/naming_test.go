@@ -0,0 +1,74 @@
+package focusreport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollidingNamesAreHashed(t *testing.T) {
+	first, second := "FOCUS Reports/2024/03/15/0001.csv.gz", "a/FOCUS/2024/03/15/0001.csv.gz"
+	for layout, relPath := range map[string]string{
+		"flat":        "20240315_0001.csv.gz",
+		"partitioned": "2024/03/15/0001.csv.gz",
+	} {
+		t.Run(layout, func(t *testing.T) {
+			client := newFakeClient(map[string]string{first: "first report", second: "second report"})
+			config := testConfig(t)
+			config.Layout = layout
+			objects, _, err := listAllFocusReports(context.Background(), client, config, "ns", "bucket")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			collisions := findCollisions(objects, config)
+			if names := collisions[relPath]; len(collisions) != 1 || len(names) != 2 {
+				t.Fatalf("findCollisions = %q, want both objects on %s", collisions, relPath)
+			}
+			// As Run does for -on-collision hash
+			config.Renames = map[string]string{
+				first:  disambiguate(relPath, first),
+				second: disambiguate(relPath, second),
+			}
+			for name, want := range config.Renames {
+				result, err := downloadSingleFile(context.Background(), client, Job{ObjectName: name, Namespace: "ns", BucketName: "bucket"}, config, &runState{progress: newProgressTracker()})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.RelativePath != want {
+					t.Errorf("%s written to %q, want %q", name, result.RelativePath, want)
+				}
+				data, err := os.ReadFile(filepath.Join(config.DownloadFolder, filepath.FromSlash(want)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wantData := string(client.objects[name]); string(data) != wantData {
+					t.Errorf("%s holds %q, want %q", want, data, wantData)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	for _, tc := range []struct {
+		name, replacement, want string
+	}{
+		{"0001.csv.gz", "_", "0001.csv.gz"},
+		{"report 12:00:00.csv", "_", "report 12_00_00.csv"},
+		{`a<b>c"d|e?f*g\h.csv`, "_", "a_b_c_d_e_f_g_h.csv"},
+		{"tab\there\x7f.csv", "_", "tab_here_.csv"},
+		{"trailing. .", "_", "trailing___"},
+		{"CON", "_", "_CON"},
+		{"nul.csv", "_", "_nul.csv"},
+		{"COM1.txt", "_", "_COM1.txt"},
+		{"CONSOLE.csv", "_", "CONSOLE.csv"},
+		{"été:rapport.csv", "-", "été-rapport.csv"},
+		{"a:b.csv", "", "a:b.csv"},
+	} {
+		if got := SanitizeFileName(tc.name, tc.replacement); got != tc.want {
+			t.Errorf("SanitizeFileName(%q, %q) = %q, want %q", tc.name, tc.replacement, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package focusreport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// collectReports builds the summary rows. Sizes come from the listing; objects
+// without a listed size (or all objects with config.ForceHead) are sized with
+// HeadObject, at most config.metadataWorkers() calls in flight. With config.NoSizes
+// no HeadObject is made and unlisted sizes are left unknown.
+func collectReports(ctx context.Context, client ObjectStorageAPI, config Config, namespace, bucketName string, objects []objectstorage.ObjectSummary) []Report {
+	rows := make([]*Report, len(objects))
+	sem := make(chan struct{}, config.metadataWorkers())
+	var wg sync.WaitGroup
+
+	for i, obj := range objects {
+		if obj.Name == nil {
+			continue
+		}
+		name := *obj.Name
+		date, _, err := reportDate(name, config)
+		if err != nil {
+			continue
+		}
+		if obj.Size != nil && !config.ForceHead {
+			r := newReport(obj, date)
+			r.Size = *obj.Size
+			rows[i] = &r
+			continue
+		}
+		if config.NoSizes {
+			r := newReport(obj, date)
+			r.SizeUnknown = true
+			rows[i] = &r
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, obj objectstorage.ObjectSummary, date time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Get actual size using HeadObject
+			r := newReport(obj, date)
+			size, err := getObjectSize(ctx, client, config.Retry, namespace, bucketName, r.Name)
+			if err != nil {
+				slog.Warn("Could not get size", "object", r.Name, "error", err)
+				size = 0
+			}
+			r.Size = size
+			rows[i] = &r
+		}(i, obj, date)
+	}
+	wg.Wait()
+
+	var reports []Report
+	for _, r := range rows {
+		if r != nil {
+			r.Region = config.Region
+			reports = append(reports, *r)
+		}
+	}
+	return reports
+}
+
+// filterBySize keeps reports whose size lies within [minSize, maxSize]; a zero
+// bound is open. Objects whose size could not be resolved count as 0 bytes.
+func filterBySize(reports []Report, minSize, maxSize int64) []Report {
+	var kept []Report
+	for _, r := range reports {
+		if r.Size < minSize || (maxSize > 0 && r.Size > maxSize) {
+			slog.Info("Skipping object outside size limits", "object", r.Name, "size", r.Size)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// objectsInReports returns the objects that have a row in reports
+func objectsInReports(objects []objectstorage.ObjectSummary, reports []Report) []objectstorage.ObjectSummary {
+	names := make(map[string]bool, len(reports))
+	for _, r := range reports {
+		names[r.Name] = true
+	}
+	var kept []objectstorage.ObjectSummary
+	for _, obj := range objects {
+		if obj.Name != nil && names[*obj.Name] {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+// applyByteBudget splits reports, in order, into those whose cumulative size
+// fits within budget and the rest; queuing stops at the first that does not fit
+func applyByteBudget(reports []Report, budget int64) (kept, skipped []Report) {
+	var total int64
+	for i, r := range reports {
+		if total+r.Size > budget {
+			for _, s := range reports[i:] {
+				slog.Info("Skipping object over -max-total-bytes budget", "object", s.Name, "size", s.Size, "budget", budget)
+			}
+			return kept, reports[i:]
+		}
+		total += r.Size
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// budgetSkippedResults records reports left out by the byte budget
+func budgetSkippedResults(skipped []Report, config Config) []OperationResult {
+	var results []OperationResult
+	now := time.Now()
+	for _, r := range skipped {
+		results = append(results, plannedResult(r, "Skipped (budget)", config, now))
+	}
+	return results
+}
+
+// latestReports splits objects into the n with the most recent report dates
+// and the rest. Objects with the same date keep their listing order.
+func latestReports(objects []objectstorage.ObjectSummary, n int, config Config) (latest, rest []objectstorage.ObjectSummary) {
+	sorted := append([]objectstorage.ObjectSummary(nil), objects...)
+	dates := make(map[string]time.Time, len(sorted))
+	for _, obj := range sorted {
+		dates[*obj.Name], _, _ = reportDate(*obj.Name, config)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return dates[*sorted[i].Name].After(dates[*sorted[j].Name])
+	})
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n], sorted[n:]
+}
+
+// trimmedResults are the operation results for the objects left out by -latest
+func trimmedResults(trimmed []objectstorage.ObjectSummary, config Config) []OperationResult {
+	var results []OperationResult
+	now := time.Now()
+	for _, obj := range trimmed {
+		date, _, _ := reportDate(*obj.Name, config)
+		results = append(results, plannedResult(newReport(obj, date), "Trimmed", config, now))
+	}
+	return results
+}
+
+// missingResults are the operation results for -object-list names that do
+// not exist in the bucket
+func missingResults(missing []string, config Config) []OperationResult {
+	var results []OperationResult
+	now := time.Now()
+	for _, name := range missing {
+		date, _, _ := reportDate(name, config)
+		result := plannedResult(Report{Name: name, Date: date}, "Not found", config, now)
+		result.Error = "object does not exist"
+		result.ErrorClass = "not_found"
+		results = append(results, result)
+	}
+	return results
+}
+
+// plannedResult is the operation result for a report that was not fetched
+func plannedResult(r Report, status string, config Config, now time.Time) OperationResult {
+	relPath, date, _ := targetPath(r.Name, config)
+	_, source, _ := reportDate(r.Name, config)
+	return OperationResult{
+		FileName:     path.Base(relPath),
+		RelativePath: relPath,
+		FileSize:     r.Size,
+		ReportDate:   date,
+		DateSource:   source,
+		Status:       status,
+		LastAttempt:  now,
+		ObjectPath:   r.Name,
+	}
+}
+
+// planDownloads prints the dry-run plan for reports and returns it as
+// operation results with Status "Dry run"
+func planDownloads(reports []Report, config Config) []OperationResult {
+	var results []OperationResult
+	var total int64
+	now := time.Now()
+	for _, r := range reports {
+		result := plannedResult(r, "Dry run", config, now)
+		fmt.Fprintf(os.Stderr, "[dry-run] %s  date=%s  size=%d bytes  → %s\n", r.Name, result.ReportDate, r.Size, result.RelativePath)
+		total += r.Size
+		results = append(results, result)
+	}
+	fmt.Fprintf(os.Stderr, "[dry-run] %d files, %d bytes would be transferred\n", len(reports), total)
+	return results
+}
+
+// sortByObjectName orders operation results by object name for -deterministic
+func sortByObjectName(results []OperationResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ObjectPath < results[j].ObjectPath
+	})
+}
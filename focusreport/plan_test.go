@@ -0,0 +1,29 @@
+package focusreport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterBySize(t *testing.T) {
+	reports := []Report{{Name: "empty", Size: 0}, {Name: "min", Size: 10}, {Name: "max", Size: 20}, {Name: "over", Size: 21}}
+	for _, tc := range []struct {
+		min, max int64
+		want     string
+	}{
+		{0, 0, "empty,min,max,over"},
+		{10, 0, "min,max,over"},
+		{11, 0, "max,over"},
+		{0, 20, "empty,min,max"},
+		{10, 20, "min,max"},
+		{1, 19, "min"},
+	} {
+		var got []string
+		for _, r := range filterBySize(reports, tc.min, tc.max) {
+			got = append(got, r.Name)
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("filterBySize(%d, %d) kept %q, want %s", tc.min, tc.max, got, tc.want)
+		}
+	}
+}
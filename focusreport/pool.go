@@ -0,0 +1,211 @@
+package focusreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Worker pool for concurrent downloads
+type WorkerPool struct {
+	jobs    chan Job
+	results chan Result
+	wg      sync.WaitGroup
+	config  Config
+	client  ObjectStorageAPI
+	ctx     context.Context
+	state   *runState
+
+	startOnce sync.Once
+	seq       atomic.Int64
+	collected []Result
+	collectWg sync.WaitGroup
+}
+
+// worker processes download jobs
+func (wp *WorkerPool) worker(id int) {
+	defer wp.wg.Done()
+
+	for job := range wp.jobs {
+		start := time.Now()
+		result, err := downloadSingleFile(wp.ctx, wp.client, job, wp.config, wp.state)
+		wp.state.metrics.observe(result, err, time.Since(start))
+		wp.state.events.finished(result, job.ObjectName, err, time.Since(start))
+		if isNotFound(err) {
+			slog.Warn("Object was deleted after listing", "object", job.ObjectName)
+		} else if err != nil {
+			slog.Error("Failed to download", "object", job.ObjectName, "attempts", result.Attempts, "error", err)
+		} else if result.Downloaded {
+			fmt.Fprintf(os.Stderr, "✓ %s → %s (%d bytes)\n", path.Base(job.ObjectName), result.RelativePath, result.FileSize)
+		}
+		wp.results <- Result{Job: job, Result: result, Error: err}
+	}
+}
+
+// NewWorkerPool creates a new worker pool
+func NewWorkerPool(ctx context.Context, client ObjectStorageAPI, config Config, state *runState) *WorkerPool {
+	return &WorkerPool{
+		jobs:    make(chan Job, config.MaxWorkers*2),
+		results: make(chan Result, config.MaxWorkers*2),
+		config:  config,
+		client:  client,
+		ctx:     ctx,
+		state:   state,
+	}
+}
+
+// Start begins processing with the specified number of workers, and drains
+// their results in the background for Collect. The collector runs before any
+// worker, so a full results buffer never stalls the workers or AddJob.
+// Calling Start more than once is a no-op.
+func (wp *WorkerPool) Start() {
+	wp.startOnce.Do(wp.start)
+}
+
+func (wp *WorkerPool) start() {
+	if !wp.config.Quiet && wp.config.ProgressEvery > 0 {
+		wp.state.progress.Start(wp.config.ProgressEvery)
+	}
+	wp.collectWg.Add(1)
+	go func() {
+		defer wp.collectWg.Done()
+		var checkpoints <-chan time.Time
+		if wp.config.Checkpoint > 0 {
+			ticker := time.NewTicker(wp.config.Checkpoint)
+			defer ticker.Stop()
+			checkpoints = ticker.C
+		}
+		for {
+			select {
+			case result, ok := <-wp.results:
+				if !ok {
+					return
+				}
+				wp.collected = append(wp.collected, result)
+			case <-checkpoints:
+				wp.checkpoint()
+			}
+		}
+	}()
+	for i := 0; i < wp.config.MaxWorkers; i++ {
+		wp.wg.Add(1)
+		go wp.worker(i + 1)
+	}
+}
+
+// AddJob adds a job to the queue, starting the pool first if needed. It only
+// blocks while every worker is busy and the job buffer is full, and returns
+// the context error instead of queuing once the pool's context is done.
+func (wp *WorkerPool) AddJob(job Job) error {
+	wp.Start()
+	if err := wp.ctx.Err(); err != nil {
+		return err
+	}
+	job.seq = wp.seq.Add(1)
+	select {
+	case wp.jobs <- job:
+		return nil
+	case <-wp.ctx.Done():
+		return wp.ctx.Err()
+	}
+}
+
+// Collect stops accepting jobs, waits for all queued jobs to finish and
+// returns their results in the order the jobs were added. All results are
+// held in memory until then, roughly a few hundred bytes per job.
+func (wp *WorkerPool) Collect() []Result {
+	wp.WaitForCompletion()
+	wp.collectWg.Wait()
+	sort.SliceStable(wp.collected, func(i, j int) bool {
+		return wp.collected[i].Job.seq < wp.collected[j].Job.seq
+	})
+	return wp.collected
+}
+
+// checkpoint writes the results collected so far to the report file, so a
+// crash before the final report still leaves a recent snapshot. It runs on
+// the collector goroutine, which owns wp.collected.
+func (wp *WorkerPool) checkpoint() {
+	collected := append([]Result(nil), wp.collected...)
+	sort.SliceStable(collected, func(i, j int) bool {
+		return collected[i].Job.seq < collected[j].Job.seq
+	})
+	results := make([]OperationResult, len(collected))
+	for i, r := range collected {
+		results[i] = r.Result
+	}
+	if wp.state.prior != nil {
+		results = mergeResults(wp.state.prior, results)
+	}
+	if err := writeReport(results, wp.config.ReportFile, wp.config.ReportFormat); err != nil {
+		slog.Warn("Could not write report checkpoint", "path", wp.config.ReportFile, "error", err)
+		return
+	}
+	slog.Debug("Report checkpoint written", "path", wp.config.ReportFile, "results", len(results))
+}
+
+// Errors returns the errors of the collected results, each prefixed with its
+// object name, joined with errors.Join, or nil when no file failed. A file
+// that failed without an error value, such as a failed post-download command,
+// contributes its report error text; objects deleted after listing are
+// included too. Call it after Collect.
+func (wp *WorkerPool) Errors() error {
+	var errs []error
+	for _, r := range wp.collected {
+		switch {
+		case r.Error != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", r.Job.ObjectName, r.Error))
+		case r.Result.Error != "":
+			errs = append(errs, fmt.Errorf("%s: %s", r.Job.ObjectName, r.Result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DownloadErrors is returned by Run together with the results when some files
+// failed while the run itself completed. It unwraps to the joined per-file
+// errors of WorkerPool.Errors, so errors.Is and errors.As reach the SDK
+// errors underneath.
+type DownloadErrors struct {
+	Failed int // files with an error
+	Err    error
+}
+
+func (e *DownloadErrors) Error() string {
+	return fmt.Sprintf("%d files failed:\n%v", e.Failed, e.Err)
+}
+
+func (e *DownloadErrors) Unwrap() error {
+	return e.Err
+}
+
+// newDownloadErrors wraps joined, as returned by WorkerPool.Errors, or
+// returns nil for a nil joined
+func newDownloadErrors(joined error) error {
+	if joined == nil {
+		return nil
+	}
+	failed := 1
+	if multi, ok := joined.(interface{ Unwrap() []error }); ok {
+		failed = len(multi.Unwrap())
+	}
+	return &DownloadErrors{Failed: failed, Err: joined}
+}
+
+// WaitForCompletion waits for all workers to finish and closes channels
+func (wp *WorkerPool) WaitForCompletion() {
+	wp.Start()
+	close(wp.jobs)
+	wp.wg.Wait()
+	if !wp.config.Quiet && wp.config.ProgressEvery > 0 {
+		wp.state.progress.Stop()
+	}
+	close(wp.results)
+}
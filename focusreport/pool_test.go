@@ -0,0 +1,45 @@
+package focusreport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolManyJobs(t *testing.T) {
+	const jobs = 500
+	objects := make(map[string]string, jobs)
+	for i := 0; i < jobs; i++ {
+		objects[fmt.Sprintf("FOCUS Reports/2024/03/15/%04d.csv.gz", i)] = "report"
+	}
+	client := newFakeClient(objects)
+	config := testConfig(t)
+	config.MaxWorkers = 2
+
+	done := make(chan []Result)
+	go func() {
+		// AddJob before Start, with far more jobs than both buffers hold
+		pool := NewWorkerPool(context.Background(), client, config, &runState{progress: newProgressTracker()})
+		for i := 0; i < jobs; i++ {
+			if err := pool.AddJob(Job{ObjectName: fmt.Sprintf("FOCUS Reports/2024/03/15/%04d.csv.gz", i), Namespace: "ns", BucketName: "bucket"}); err != nil {
+				t.Error(err)
+			}
+		}
+		done <- pool.Collect()
+	}()
+
+	select {
+	case results := <-done:
+		if len(results) != jobs {
+			t.Fatalf("collected %d results, want %d", len(results), jobs)
+		}
+		for i, r := range results {
+			if want := fmt.Sprintf("FOCUS Reports/2024/03/15/%04d.csv.gz", i); r.Job.ObjectName != want || !r.Result.Downloaded {
+				t.Fatalf("result %d is %s (downloaded %v), want %s downloaded", i, r.Job.ObjectName, r.Result.Downloaded, want)
+			}
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("worker pool deadlocked")
+	}
+}
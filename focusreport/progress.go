@@ -0,0 +1,71 @@
+package focusreport
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressTracker aggregates transfer progress across all workers so that a
+// single periodic line reports overall throughput
+type progressTracker struct {
+	bytes  atomic.Int64
+	active atomic.Int32
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// progressReader feeds bytes read into a progressTracker
+type progressReader struct {
+	r io.Reader
+	p *progressTracker
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.p.bytes.Add(int64(n))
+	return n, err
+}
+
+// newProgressTracker creates an idle progress tracker
+func newProgressTracker() *progressTracker {
+	return &progressTracker{done: make(chan struct{})}
+}
+
+// Start logs aggregated progress every interval until Stop is called
+func (p *progressTracker) Start(interval time.Duration) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		var last int64
+		lastTick := start
+		for {
+			select {
+			case <-p.done:
+				return
+			case now := <-ticker.C:
+				total := p.bytes.Load()
+				rate := float64(total-last) / now.Sub(lastTick).Seconds() / 1e6
+				slog.Info("Progress",
+					"transferred_mb", fmt.Sprintf("%.1f", float64(total)/1e6),
+					"mbps", fmt.Sprintf("%.2f", rate),
+					"active", p.active.Load(),
+					"elapsed", now.Sub(start).Round(time.Second))
+				last, lastTick = total, now
+			}
+		}
+	}()
+}
+
+// Stop ends periodic progress logging
+func (p *progressTracker) Stop() {
+	close(p.done)
+	p.wg.Wait()
+}
@@ -0,0 +1,386 @@
+package focusreport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// objectEntry is an element of the -objects-json output
+type objectEntry struct {
+	Name          string     `json:"name"`
+	Size          *int64     `json:"size,omitempty"` // absent when the listing had no size
+	ReportDate    string     `json:"report_date,omitempty"`
+	ETag          string     `json:"etag,omitempty"`
+	MD5           string     `json:"md5,omitempty"`
+	TimeCreated   *time.Time `json:"time_created,omitempty"`
+	StorageTier   string     `json:"storage_tier,omitempty"`
+	ArchivalState string     `json:"archival_state,omitempty"`
+}
+
+// writeObjectsJSON writes objects as a JSON array to filename, or to stdout
+// for "-"
+func writeObjectsJSON(objects []objectstorage.ObjectSummary, config Config, filename string) error {
+	entries := make([]objectEntry, 0, len(objects))
+	for _, obj := range objects {
+		if obj.Name == nil {
+			continue
+		}
+		e := objectEntry{
+			Name:          *obj.Name,
+			Size:          obj.Size,
+			ETag:          stringValue(obj.Etag),
+			MD5:           stringValue(obj.Md5),
+			StorageTier:   string(obj.StorageTier),
+			ArchivalState: string(obj.ArchivalState),
+		}
+		if date, _, err := reportDate(*obj.Name, config); err == nil {
+			e.ReportDate = date.Format("2006-01-02")
+		}
+		if obj.TimeCreated != nil {
+			t := obj.TimeCreated.UTC()
+			e.TimeCreated = &t
+		}
+		entries = append(entries, e)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if filename == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// computeStats aggregates the operation results of a run
+func computeStats(listed int, results []OperationResult, elapsed time.Duration) Stats {
+	stats := Stats{Listed: listed, Attempted: len(results), Elapsed: elapsed, ElapsedSec: elapsed.Seconds()}
+	for _, r := range results {
+		switch {
+		case r.Downloaded:
+			stats.Downloaded++
+			stats.TotalBytes += r.FileSize
+		case r.Error != "":
+			stats.Failed++
+			class := r.ErrorClass
+			if class == "" {
+				class = "other"
+			}
+			if stats.FailuresByClass == nil {
+				stats.FailuresByClass = make(map[string]int)
+			}
+			stats.FailuresByClass[class]++
+		default:
+			stats.Skipped++
+		}
+	}
+	if elapsed > 0 {
+		stats.Throughput = float64(stats.TotalBytes) / elapsed.Seconds() / 1e6
+	}
+	return stats
+}
+
+// statsFilePath returns the companion stats file for an operation report,
+// e.g. download_report.stats.json for download_report.csv or
+// download_report.csv.gz
+func statsFilePath(reportFile string) string {
+	reportFile = strings.TrimSuffix(reportFile, ".gz")
+	return strings.TrimSuffix(reportFile, filepath.Ext(reportFile)) + ".stats.json"
+}
+
+// writeStatsFile saves the run summary as JSON next to the operation report
+func writeStatsFile(stats Stats, filename string) error {
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// writeReport writes the operation report in the configured format. It is
+// written to a temporary file and renamed into place, so a crash mid-write
+// leaves the previous report or checkpoint intact.
+func writeReport(results []OperationResult, filename, format string) error {
+	tmpPath := filename + ".tmp"
+	compress := strings.HasSuffix(filename, ".gz")
+	var err error
+	if format == "json" {
+		err = writeOperationReportJSON(results, tmpPath, compress)
+	} else {
+		err = writeOperationReport(results, tmpPath, compress)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, filename)
+}
+
+// writeOperationReportJSON writes the operation report as a pretty-printed JSON array
+func writeOperationReportJSON(results []OperationResult, filename string, compress bool) error {
+	if results == nil {
+		results = []OperationResult{}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := createReportFile(filename, compress)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// reportFile is an output file, gzip-compressed with -compress-reports. Close
+// finishes the gzip stream before closing the file and may be called again.
+type reportFile struct {
+	file   *os.File
+	gz     *gzip.Writer // nil when not compressed
+	closed bool
+}
+
+func createReportFile(filename string, compress bool) (*reportFile, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &reportFile{file: file}
+	if compress {
+		f.gz = gzip.NewWriter(file)
+	}
+	return f, nil
+}
+
+func (f *reportFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.file.Write(p)
+}
+
+func (f *reportFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	var err error
+	if f.gz != nil {
+		err = f.gz.Close()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// retryStatuses are the operation report statuses -resume-report retries
+var retryStatuses = map[string]bool{"Failed": true, "Timeout": true, "Needs restore": true}
+
+// readOperationReport reads an operation report written by writeReport: JSON
+// for a .json file, CSV otherwise, either of them gzipped for a .gz file.
+// Values that do not parse are left at zero; only object_path and status
+// matter to -resume-report.
+func readOperationReport(filename string) ([]OperationResult, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	name := filename
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		var results []OperationResult
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, h := range records[0] {
+		columns[h] = i
+	}
+	if _, ok := columns["object_path"]; !ok {
+		return nil, errors.New("no object_path column; the report was written by an older version")
+	}
+
+	var results []OperationResult
+	for _, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		getInt := func(name string) int64 {
+			n, _ := strconv.ParseInt(get(name), 10, 64)
+			return n
+		}
+		r := OperationResult{
+			FileName:         get("file_name"),
+			RelativePath:     get("relative_path"),
+			FileSize:         getInt("file_size"),
+			ReportDate:       get("report_date"),
+			DateSource:       get("date_source"),
+			Status:           get("status"),
+			Error:            get("error"),
+			Attempts:         int(getInt("attempts")),
+			CompressedSize:   getInt("compressed_size"),
+			DecompressedSize: getInt("decompressed_size"),
+			ErrorClass:       get("error_class"),
+			DurationMs:       getInt("duration_ms"),
+			OriginalFileName: get("original_file_name"),
+			ObjectPath:       get("object_path"),
+		}
+		r.Downloaded, _ = strconv.ParseBool(get("downloaded"))
+		r.Resumed, _ = strconv.ParseBool(get("resumed"))
+		r.LastAttempt, _ = time.Parse(time.RFC3339, get("last_attempt"))
+		r.ThroughputMBps, _ = strconv.ParseFloat(get("throughput_mbps"), 64)
+		if missing := get("missing_columns"); missing != "" {
+			r.MissingColumns = strings.Split(missing, ";")
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// retryableObjects returns the object names of the prior results whose
+// status is in retryStatuses, in report order
+func retryableObjects(prior []OperationResult) []string {
+	var names []string
+	for _, r := range prior {
+		if retryStatuses[r.Status] && r.ObjectPath != "" {
+			names = append(names, r.ObjectPath)
+		}
+	}
+	return names
+}
+
+// mergeResults replaces the prior rows of the objects in results with the
+// new ones, keeping the order of prior; rows of other objects are appended
+func mergeResults(prior, results []OperationResult) []OperationResult {
+	byObject := make(map[string]OperationResult, len(results))
+	for _, r := range results {
+		byObject[r.ObjectPath] = r
+	}
+	merged := make([]OperationResult, 0, len(prior)+len(results))
+	for _, r := range prior {
+		if updated, ok := byObject[r.ObjectPath]; ok && r.ObjectPath != "" {
+			r = updated
+			delete(byObject, r.ObjectPath)
+		}
+		merged = append(merged, r)
+	}
+	for _, r := range results {
+		if _, ok := byObject[r.ObjectPath]; ok {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+func writeOperationReport(results []OperationResult, filename string, compress bool) error {
+	file, err := createReportFile(filename, compress)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	// Write header
+	header := []string{
+		"file_name",
+		"relative_path",
+		"file_size",
+		"report_date",
+		"status",
+		"downloaded",
+		"error",
+		"last_attempt",
+		"attempts",
+		"compressed_size",
+		"decompressed_size",
+		"missing_columns",
+		"resumed",
+		"error_class",
+		"date_source",
+		"duration_ms",
+		"throughput_mbps",
+		"original_file_name",
+		"object_path",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	// Write records
+	for _, result := range results {
+		record := []string{
+			result.FileName,
+			result.RelativePath,
+			fmt.Sprintf("%d", result.FileSize),
+			result.ReportDate,
+			result.Status,
+			strconv.FormatBool(result.Downloaded),
+			result.Error,
+			result.LastAttempt.Format(time.RFC3339),
+			strconv.Itoa(result.Attempts),
+			strconv.FormatInt(result.CompressedSize, 10),
+			strconv.FormatInt(result.DecompressedSize, 10),
+			strings.Join(result.MissingColumns, ";"),
+			strconv.FormatBool(result.Resumed),
+			result.ErrorClass,
+			result.DateSource,
+			strconv.FormatInt(result.DurationMs, 10),
+			strconv.FormatFloat(result.ThroughputMBps, 'f', 2, 64),
+			result.OriginalFileName,
+			result.ObjectPath,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
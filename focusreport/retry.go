@@ -0,0 +1,201 @@
+package focusreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// RetryConfig controls per-call timeouts and retries of transient OCI errors
+type RetryConfig struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// RequestTimeout bounds each OCI call; for downloads it is the longest
+	// allowed stall without receiving data. Zero disables it.
+	RequestTimeout time.Duration
+}
+
+// errRequestTimeout marks an OCI call that exceeded -request-timeout
+var errRequestTimeout = errors.New("request timed out")
+
+// requestTimer cancels a per-call context with errRequestTimeout once its
+// timeout elapses without Reset being called
+type requestTimer struct {
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelCauseFunc
+}
+
+// newRequestContext derives the context for one OCI call from ctx, so overall
+// cancellation still applies; a zero timeout only inherits ctx
+func newRequestContext(ctx context.Context, timeout time.Duration) (context.Context, *requestTimer) {
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	t := &requestTimer{timeout: timeout, cancel: cancel}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() { cancel(errRequestTimeout) })
+	}
+	return reqCtx, t
+}
+
+// Reset restarts the timeout, e.g. whenever a download receives data
+func (t *requestTimer) Reset() {
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+// Stop releases the timer and the derived context
+func (t *requestTimer) Stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.cancel(nil)
+}
+
+// timeoutErr maps an error caused by the request timer to errRequestTimeout
+func timeoutErr(reqCtx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(reqCtx), errRequestTimeout) {
+		return fmt.Errorf("%w: %v", errRequestTimeout, err)
+	}
+	return err
+}
+
+// callWithTimeout runs a single OCI call under the per-request timeout
+func callWithTimeout(ctx context.Context, timeout time.Duration, call func(ctx context.Context) error) error {
+	reqCtx, timer := newRequestContext(ctx, timeout)
+	defer timer.Stop()
+	return timeoutErr(reqCtx, call(reqCtx))
+}
+
+// isRetryable reports whether err is a transient error worth retrying
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if serviceErr, ok := common.IsServiceError(err); ok {
+		code := serviceErr.GetHTTPStatusCode()
+		return code == 429 || code >= 500
+	}
+	if errors.Is(err, errRequestTimeout) || errors.Is(err, errChecksumMismatch) || errors.Is(err, errSimulatedFailure) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfterError is a throttled service error with the delay its
+// Retry-After header asked for. It still satisfies common.ServiceError.
+type retryAfterError struct {
+	common.ServiceError
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// withRetryAfter attaches the Retry-After delay of a 429 response to err;
+// any other error is returned unchanged
+func withRetryAfter(err error, raw *http.Response) error {
+	if err == nil || raw == nil || raw.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+	serviceErr, ok := common.IsServiceError(err)
+	if !ok {
+		return err
+	}
+	delay, ok := parseRetryAfter(raw.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return err
+	}
+	return &retryAfterError{ServiceError: serviceErr, err: err, delay: delay}
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if when.Before(now) {
+		return 0, true
+	}
+	return when.Sub(now), true
+}
+
+// retryDelay is the wait before the given retry (1-based) after err: the
+// server's Retry-After when it sent one, capped at retry.MaxDelay, otherwise
+// the exponential backoff
+func retryDelay(retry RetryConfig, attempt int, err error) time.Duration {
+	var throttled *retryAfterError
+	if !errors.As(err, &throttled) {
+		return backoffDelay(retry, attempt)
+	}
+	if retry.MaxDelay > 0 && throttled.delay > retry.MaxDelay {
+		return retry.MaxDelay
+	}
+	return throttled.delay
+}
+
+// backoffDelay returns the jittered exponential delay before the given retry
+// (1-based). A shift that would overflow, with a large -max-retries, is
+// MaxDelay.
+func backoffDelay(retry RetryConfig, attempt int) time.Duration {
+	delay := retry.MaxDelay
+	if shift := attempt - 1; shift >= 0 && shift < 63 && retry.BaseDelay <= math.MaxInt64>>shift {
+		if shifted := retry.BaseDelay << shift; retry.MaxDelay <= 0 || shifted < retry.MaxDelay {
+			delay = shifted
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Jitter within [delay/2, delay] so workers do not retry in lockstep
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// withRetry runs op until it succeeds, fails permanently, or retries are exhausted.
+// It returns the number of attempts made.
+func withRetry(ctx context.Context, retry RetryConfig, desc string, op func() error) (int, error) {
+	attempt := 0
+	for {
+		attempt++
+		err := op()
+		if err == nil || !isRetryable(err) || attempt > retry.MaxRetries {
+			return attempt, err
+		}
+
+		delay := retryDelay(retry, attempt, err)
+		slog.Warn("Retrying", "op", desc, "delay", delay, "attempt", attempt+1, "max_attempts", retry.MaxRetries+1, "error", err)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
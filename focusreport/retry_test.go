@@ -0,0 +1,60 @@
+package focusreport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.value, now)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	retry := RetryConfig{BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	for _, tc := range []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{5, 8 * time.Second, 16 * time.Second},
+		{6, 15 * time.Second, 30 * time.Second},
+		{34, 15 * time.Second, 30 * time.Second},
+		{63, 15 * time.Second, 30 * time.Second},
+		{64, 15 * time.Second, 30 * time.Second},
+		{1000, 15 * time.Second, 30 * time.Second},
+	} {
+		for i := 0; i < 20; i++ {
+			if got := backoffDelay(retry, tc.attempt); got < tc.min || got > tc.max {
+				t.Errorf("backoffDelay(attempt %d) = %v, want between %v and %v", tc.attempt, got, tc.min, tc.max)
+				break
+			}
+		}
+	}
+	// The shift wraps around to about 2s here without the overflow check
+	odd := RetryConfig{BaseDelay: 1<<33 + 1, MaxDelay: 30 * time.Second}
+	if got := backoffDelay(odd, 32); got < 15*time.Second || got > 30*time.Second {
+		t.Errorf("backoffDelay(%v base, attempt 32) = %v, want between 15s and 30s", odd.BaseDelay, got)
+	}
+	if got := backoffDelay(RetryConfig{}, 3); got != 0 {
+		t.Errorf("backoffDelay without delays = %v, want 0", got)
+	}
+}
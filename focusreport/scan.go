@@ -0,0 +1,121 @@
+package focusreport
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ScanResult is a row of the -scan-local report
+type ScanResult struct {
+	RelativePath string
+	ReportDate   string // empty when the path has no date
+	Size         int64
+	Status       string // OK, Empty or InvalidGzip
+	Detail       string
+}
+
+// ScanLocal checks the files under folder without contacting the bucket:
+// each must be non-empty and, with checkGzip, a .gz file must decompress
+// to the end with a valid checksum. At most workers files are read at a
+// time. Results are sorted by path.
+func ScanLocal(folder string, checkGzip bool, workers int) ([]ScanResult, error) {
+	var results []ScanResult
+	err := filepath.WalkDir(folder, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || isSidecar(p) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(folder, p)
+		if err != nil {
+			return err
+		}
+		result := ScanResult{RelativePath: filepath.ToSlash(rel), Size: info.Size(), Status: "OK"}
+		if date, ok := localReportDate(result.RelativePath); ok {
+			result.ReportDate = date.Format("2006-01-02")
+		}
+		if result.Size == 0 {
+			result.Status = "Empty"
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if checkGzip {
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for i := range results {
+			result := &results[i]
+			if result.Status != "OK" || !strings.HasSuffix(result.RelativePath, ".gz") {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := checkGzipFile(filepath.Join(folder, filepath.FromSlash(result.RelativePath))); err != nil {
+					result.Status = "InvalidGzip"
+					result.Detail = err.Error()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].RelativePath < results[j].RelativePath
+	})
+	return results, nil
+}
+
+// checkGzipFile reads filename through gzip to the end, which verifies the
+// CRC and length of every member
+func checkGzipFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	_, err = io.Copy(io.Discard, gz)
+	return err
+}
+
+// WriteScanCSV writes the -scan-local report
+func WriteScanCSV(results []ScanResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"relative_path", "report_date", "size", "status", "detail"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{r.RelativePath, r.ReportDate, strconv.FormatInt(r.Size, 10), r.Status, r.Detail}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
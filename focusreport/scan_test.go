@@ -0,0 +1,54 @@
+package focusreport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanLocal(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("BilledCost\n1.0\n"))
+	w.Close()
+	for name, data := range map[string][]byte{
+		"20240315_0001.csv.gz":      gz.Bytes(),
+		"20240316_0001.csv.gz":      gz.Bytes()[:gz.Len()/2],
+		"20240317_0001.csv":         nil,
+		"2024/03/18/0001.csv.gz":    gz.Bytes(),
+		"notes.txt":                 []byte("no date"),
+		"20240315_0001.csv.gz.etag": []byte("etag"),
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := ScanLocal(dir, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, fmt.Sprintf("%s %s %s", r.RelativePath, r.ReportDate, r.Status))
+	}
+	want := []string{
+		"2024/03/18/0001.csv.gz 2024-03-18 OK",
+		"20240315_0001.csv.gz 2024-03-15 OK",
+		"20240316_0001.csv.gz 2024-03-16 InvalidGzip",
+		"20240317_0001.csv 2024-03-17 Empty",
+		"notes.txt  OK",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("scanLocal returned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
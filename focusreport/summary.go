@@ -0,0 +1,214 @@
+package focusreport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+)
+
+// Report is one row of the summary CSV
+type Report struct {
+	Name        string
+	Size        int64
+	SizeUnknown bool // -no-sizes and the listing had no size
+	Date        time.Time
+	ETag        string
+	MD5         string
+	TimeCreated time.Time
+	StorageTier string
+	Region      string // region the object was listed in, empty if unknown
+}
+
+// newReport builds a summary row from the listing fields of obj
+func newReport(obj objectstorage.ObjectSummary, date time.Time) Report {
+	r := Report{
+		Name:        stringValue(obj.Name),
+		Date:        date,
+		ETag:        stringValue(obj.Etag),
+		MD5:         stringValue(obj.Md5),
+		StorageTier: string(obj.StorageTier),
+	}
+	if obj.TimeCreated != nil {
+		r.TimeCreated = obj.TimeCreated.Time
+	}
+	return r
+}
+
+// ParseSummarySort parses a -summary-sort value of the form field[,direction],
+// where field is date, size or name and direction is asc or desc. Without a
+// direction, date and size sort descending and name ascending. It returns the
+// less function for sort.SliceStable.
+func ParseSummarySort(spec string) (func(a, b Report) bool, error) {
+	field, dir, hasDir := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ",")
+	var less func(a, b Report) bool
+	desc := true
+	switch field {
+	case "date":
+		less = func(a, b Report) bool { return a.Date.Before(b.Date) }
+	case "size":
+		less = func(a, b Report) bool { return a.Size < b.Size }
+	case "name":
+		less = func(a, b Report) bool { return a.Name < b.Name }
+		desc = false
+	default:
+		return nil, fmt.Errorf("invalid -summary-sort field %q: must be date, size or name", field)
+	}
+	if hasDir {
+		switch strings.TrimSpace(dir) {
+		case "asc":
+			desc = false
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("invalid -summary-sort direction %q: must be asc or desc", dir)
+		}
+	}
+	if desc {
+		return func(a, b Report) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
+
+// monthlySummaryPath is the -summary-split month file for month (YYYY-MM)
+// next to filename, e.g. oci_focus_reports_2024-03.csv
+func monthlySummaryPath(filename, month string) string {
+	return suffixedPath(filename, month)
+}
+
+// suffixedPath inserts _suffix before the extension of filename, keeping a
+// trailing .gz last
+func suffixedPath(filename, suffix string) string {
+	trimmed := strings.TrimSuffix(filename, ".gz")
+	ext := filepath.Ext(trimmed)
+	return strings.TrimSuffix(trimmed, ext) + "_" + suffix + ext + filename[len(trimmed):]
+}
+
+// writeMonthlySummaries writes one summary CSV per report month, keeping the
+// order of reports within each month, and returns the files written
+func writeMonthlySummaries(reports []Report, filename, bucketName, tenancyID string) ([]string, error) {
+	var months []string
+	byMonth := make(map[string][]Report)
+	for _, r := range reports {
+		month := r.Date.Format("2006-01")
+		if _, ok := byMonth[month]; !ok {
+			months = append(months, month)
+		}
+		byMonth[month] = append(byMonth[month], r)
+	}
+	sort.Strings(months)
+
+	var files []string
+	for _, month := range months {
+		name := monthlySummaryPath(filename, month)
+		if err := writeSummaryCSV(byMonth[month], name, bucketName, tenancyID); err != nil {
+			return files, fmt.Errorf("%s: %w", name, err)
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// writeSummaryCSV writes the summary of all FOCUS reports, creating parent
+// directories of filename as needed
+func writeSummaryCSV(reports []Report, filename, bucketName, tenancyID string) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	file, err := createReportFile(filename, strings.HasSuffix(filename, ".gz"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	header := []string{
+		"bucket_name",
+		"object_name",
+		"size_bytes",
+		"report_date",
+		"tenancy_ocid",
+		"etag",
+		"md5",
+		"time_created",
+		"storage_tier",
+		"object_path",
+		"region",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		size := fmt.Sprintf("%d", r.Size)
+		if r.SizeUnknown {
+			size = ""
+		}
+		var timeCreated string
+		if !r.TimeCreated.IsZero() {
+			timeCreated = r.TimeCreated.UTC().Format(time.RFC3339)
+		}
+		record := []string{
+			bucketName,
+			path.Base(r.Name),
+			size,
+			r.Date.Format("2006-01-02"),
+			tenancyID,
+			r.ETag,
+			r.MD5,
+			timeCreated,
+			r.StorageTier,
+			r.Name,
+			r.Region,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	// The CSV must be flushed into the gzip stream before it is closed
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeSummaries sorts reports per config.SummaryLess and writes the summary
+// CSV and, with config.SummarySplit, the monthly ones. capped is appended to
+// the messages when the listing was cut short.
+func writeSummaries(reports []Report, config Config, capped string) error {
+	// Sort per -summary-sort (date descending by default), ties kept in listing order
+	less := config.SummaryLess
+	if less == nil {
+		less, _ = ParseSummarySort("date,desc")
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return less(reports[i], reports[j])
+	})
+
+	if !config.SplitOnly {
+		if err := writeSummaryCSV(reports, config.SummaryFile, config.BucketName, config.TenancyID); err != nil {
+			return fmt.Errorf("writing summary CSV %s: %w", config.SummaryFile, err)
+		}
+		fmt.Fprintf(os.Stderr, "CSV file generated successfully: %s (%d reports%s)\n", config.SummaryFile, len(reports), capped)
+	}
+	if config.SummarySplit == "month" {
+		files, err := writeMonthlySummaries(reports, config.SummaryFile, config.BucketName, config.TenancyID)
+		if err != nil {
+			return fmt.Errorf("writing monthly summary CSV %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Monthly summary CSVs generated: %d files like %s%s\n", len(files), monthlySummaryPath(config.SummaryFile, "YYYY-MM"), capped)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package focusreport
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// throttleChunk bounds a single throttled read, so the limiter paces a
+// download in small steps instead of large bursts
+const throttleChunk = 32 << 10
+
+// rateLimiter is a token bucket of bytes shared by all workers, refilled at
+// rate bytes per second with a burst of one second's worth. A read reserves
+// its tokens up front and waits for any shortfall, so concurrent downloads
+// together stay at the rate.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: time.Now()}
+}
+
+// wait reserves n bytes and blocks until the bucket covers them or ctx is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refund returns reserved bytes that were not read
+func (l *rateLimiter) refund(n int) {
+	l.mu.Lock()
+	l.tokens += float64(n)
+	l.mu.Unlock()
+}
+
+// throttledReader paces reads through a rateLimiter. Waiting for the limiter
+// resets the optional request timer, so throttling is not taken for a stall.
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+	timer   *requestTimer
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	if err := tr.limiter.wait(tr.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	if tr.timer != nil {
+		tr.timer.Reset()
+	}
+	n, err := tr.r.Read(p)
+	if n < len(p) {
+		tr.limiter.refund(len(p) - n)
+	}
+	return n, err
+}
@@ -0,0 +1,54 @@
+package focusreport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSharedCap(t *testing.T) {
+	const rate = 64 << 10
+	limiter := newRateLimiter(rate)
+	start := time.Now()
+
+	// Two readers share the limit; beyond the one second burst the bucket
+	// allows, together they cannot exceed rate bytes per second
+	var wg sync.WaitGroup
+	var total atomic.Int64
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := &throttledReader{ctx: context.Background(), r: bytes.NewReader(make([]byte, rate)), limiter: limiter}
+			n, err := io.Copy(io.Discard, r)
+			if err != nil {
+				t.Error(err)
+			}
+			total.Add(n)
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	if total.Load() != 2*rate {
+		t.Fatalf("read %d bytes, want %d", total.Load(), 2*rate)
+	}
+	if allowed := rate + int64(elapsed.Seconds()*rate); total.Load() > allowed {
+		t.Errorf("read %d bytes in %v, more than the %d the limit allows", total.Load(), elapsed, allowed)
+	}
+}
+
+func TestRateLimiterCancelled(t *testing.T) {
+	limiter := newRateLimiter(1 << 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// The burst is 1KiB, so reserving 1MiB waits far past the deadline
+	if err := limiter.wait(ctx, 1<<20); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait returned %v, want context.DeadlineExceeded", err)
+	}
+}
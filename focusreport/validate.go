@@ -0,0 +1,78 @@
+package focusreport
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// openReportFile opens a downloaded report for reading, transparently
+// gunzipping it if it still has a .gz suffix
+func openReportFile(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(filePath, ".gz") {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: gz, file: file}, nil
+}
+
+// focusColumns are the FOCUS columns every downloaded report must carry. It is
+// the mandatory set shared by FOCUS 1.0 and the 1.0-preview that OCI exports,
+// so it leaves out the columns that were renamed between the two.
+var focusColumns = []string{
+	"BilledCost",
+	"BillingAccountId",
+	"BillingCurrency",
+	"BillingPeriodEnd",
+	"BillingPeriodStart",
+	"ChargeCategory",
+	"ChargePeriodEnd",
+	"ChargePeriodStart",
+	"EffectiveCost",
+	"ListCost",
+	"ServiceCategory",
+	"ServiceName",
+}
+
+// missingFocusColumns reads the CSV header of the file at filePath, gunzipping
+// it if it still has a .gz suffix, and returns the focusColumns it lacks
+func missingFocusColumns(filePath string) ([]string, error) {
+	r, err := openReportFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cr := csv.NewReader(r)
+	cr.LazyQuotes = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	present := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		present[strings.TrimSpace(name)] = true
+	}
+
+	var missing []string
+	for _, name := range focusColumns {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,205 @@
+package focusreport
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// verifyResult is a row of the -verify-only report
+type verifyResult struct {
+	RelativePath string
+	ObjectName   string // empty for files with no remote object
+	ReportDate   string
+	RemoteSize   int64
+	LocalSize    int64
+	RemoteETag   string
+	LocalETag    string // from the ETag sidecar or the state file, empty if unknown
+	Status       string // OK, SizeMismatch, EtagMismatch, MissingRemote or UnmatchedLocal
+	Detail       string
+}
+
+// localReportDate returns the report date encoded in the path of a local
+// file: a YYYYMMDD_ name prefix (flat layout) or YYYY/MM/DD directories
+// (partitioned layout)
+func localReportDate(relPath string) (time.Time, bool) {
+	if date, err := parseDateFromName(relPath); err == nil {
+		return date, true
+	}
+	return parseFilenameDate(path.Base(relPath))
+}
+
+// isSidecar reports whether p is a file the downloader keeps next to the
+// reports rather than a report
+func isSidecar(p string) bool {
+	return strings.HasSuffix(p, ".etag") || strings.HasSuffix(p, ".tmp") || strings.HasSuffix(p, ".tmp.json")
+}
+
+// verifyLocal audits the files under config.DownloadFolder against reports,
+// hashing at most config.MaxWorkers files at a time. A file matching a report
+// is OK unless its size differs, or its recorded ETag or the MD5 of its
+// content differs from the object's. Date-prefixed files within the date
+// range with no report are MissingRemote; files that do not look like a
+// downloaded report are UnmatchedLocal. Results are sorted by path.
+func verifyLocal(reports []Report, manifest *Manifest, config Config) ([]verifyResult, error) {
+	byPath := make(map[string]Report, len(reports))
+	for _, r := range reports {
+		relPath, _, _ := targetPath(r.Name, config)
+		basePath := filepath.Join(config.DownloadFolder, filepath.FromSlash(relPath))
+		relPath += strings.TrimPrefix(localPath(basePath, config.InferExtension), basePath)
+		byPath[relPath] = r
+	}
+
+	var results []verifyResult
+	var matched []int // indexes into results still to be checked
+	err := filepath.WalkDir(config.DownloadFolder, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		if isSidecar(p) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(config.DownloadFolder, p)
+		if err != nil {
+			return err
+		}
+		result := verifyResult{RelativePath: filepath.ToSlash(rel), LocalSize: info.Size()}
+		if r, ok := byPath[result.RelativePath]; ok {
+			result.ObjectName = r.Name
+			result.ReportDate = r.Date.Format("2006-01-02")
+			result.RemoteSize = r.Size
+			result.RemoteETag = r.ETag
+			matched = append(matched, len(results))
+		} else if date, ok := localReportDate(result.RelativePath); !ok {
+			result.Status = "UnmatchedLocal"
+		} else if dateInRange(date, config.FromDate, config.ToDate) {
+			result.ReportDate = date.Format("2006-01-02")
+			result.Status = "MissingRemote"
+		} else {
+			// Outside the listed date range, so its object was not looked for
+			return nil
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sem := make(chan struct{}, config.MaxWorkers)
+	var wg sync.WaitGroup
+	for _, i := range matched {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *verifyResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := byPath[result.RelativePath]
+			verifyFile(result, r, manifest, config)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].RelativePath < results[j].RelativePath
+	})
+	return results, nil
+}
+
+// verifyFile sets the status of a local file that matches report r
+func verifyFile(result *verifyResult, r Report, manifest *Manifest, config Config) {
+	filePath := filepath.Join(config.DownloadFolder, filepath.FromSlash(result.RelativePath))
+	_, _, decompress := targetPath(r.Name, config)
+	result.Status = "OK"
+
+	// Decompressed files cannot be compared with the stored object
+	if !decompress && !r.SizeUnknown && result.LocalSize != r.Size {
+		result.Status = "SizeMismatch"
+		result.Detail = fmt.Sprintf("local %d bytes, remote %d bytes", result.LocalSize, r.Size)
+		return
+	}
+
+	if config.ETagSidecars {
+		result.LocalETag = readETagSidecar(filePath)
+	} else if manifest != nil {
+		result.LocalETag, _ = manifest.ETag(r.Name)
+	}
+	if result.LocalETag != "" && r.ETag != "" && result.LocalETag != r.ETag {
+		result.Status = "EtagMismatch"
+		result.Detail = "the object changed since it was downloaded"
+		return
+	}
+
+	// Multipart uploads carry no MD5 of the whole content
+	if decompress || r.MD5 == "" || strings.Contains(r.MD5, "-") {
+		return
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		result.Status = "EtagMismatch"
+		result.Detail = err.Error()
+		return
+	}
+	defer f.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		result.Status = "EtagMismatch"
+		result.Detail = err.Error()
+		return
+	}
+	if got := base64.StdEncoding.EncodeToString(hash.Sum(nil)); got != r.MD5 {
+		result.Status = "EtagMismatch"
+		result.Detail = fmt.Sprintf("local MD5 %s, remote MD5 %s", got, r.MD5)
+	}
+}
+
+// writeVerifyCSV writes the -verify-only report
+func writeVerifyCSV(results []verifyResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"relative_path", "object_name", "report_date", "remote_size", "local_size", "remote_etag", "local_etag", "status", "detail"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		remoteSize := ""
+		if r.ObjectName != "" {
+			remoteSize = strconv.FormatInt(r.RemoteSize, 10)
+		}
+		record := []string{
+			r.RelativePath,
+			r.ObjectName,
+			r.ReportDate,
+			remoteSize,
+			strconv.FormatInt(r.LocalSize, 10),
+			r.RemoteETag,
+			r.LocalETag,
+			r.Status,
+			r.Detail,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
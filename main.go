@@ -0,0 +1,1262 @@
+// Command oci_focus_download downloads the FOCUS cost reports of an OCI
+// tenancy. It parses the flags, authenticates and resolves the namespace and
+// bucket, then hands the work to focusreport.Run.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eugsim1/focus_report/focusreport"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	"github.com/oracle/oci-go-sdk/v65/objectstorage"
+	"gopkg.in/yaml.v3"
+)
+
+// Worker count limits; -allow-high-concurrency lifts the first up to the second
+const (
+	defaultMaxWorkers = 16
+	hardMaxWorkers    = 256
+)
+
+// Metadata workers per download worker when -metadata-workers is not given,
+// and the most that default may reach
+const (
+	metadataWorkersPerWorker = 4
+	defaultMetadataWorkers   = 64
+)
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// globList is a repeatable flag of path.Match patterns
+type globList []string
+
+func (g *globList) String() string { return strings.Join(*g, ",") }
+
+func (g *globList) Set(value string) error {
+	if _, err := path.Match(value, ""); err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	*g = append(*g, value)
+	return nil
+}
+
+// sseCustomerKey holds the request fields for an SSE-C encrypted bucket
+type sseCustomerKey struct {
+	Algorithm string
+	Key       string // base64 AES-256 key
+	KeySHA256 string // base64 SHA-256 of the raw key
+}
+
+// loadSSECustomerKey reads a base64-encoded AES-256 key from filename
+func loadSSECustomerKey(filename string) (*sseCustomerKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, AES-256 needs 32", len(raw))
+	}
+	sum := sha256.Sum256(raw)
+	return &sseCustomerKey{
+		Algorithm: "AES256",
+		Key:       base64.StdEncoding.EncodeToString(raw),
+		KeySHA256: base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// sseCustomerClient adds the SSE-C key to every request that reads object
+// data or metadata; other calls go straight to the wrapped client
+type sseCustomerClient struct {
+	focusreport.ObjectStorageAPI
+	key *sseCustomerKey
+}
+
+func (c sseCustomerClient) HeadObject(ctx context.Context, req objectstorage.HeadObjectRequest) (objectstorage.HeadObjectResponse, error) {
+	req.OpcSseCustomerAlgorithm = &c.key.Algorithm
+	req.OpcSseCustomerKey = &c.key.Key
+	req.OpcSseCustomerKeySha256 = &c.key.KeySHA256
+	return c.ObjectStorageAPI.HeadObject(ctx, req)
+}
+
+func (c sseCustomerClient) GetObject(ctx context.Context, req objectstorage.GetObjectRequest) (objectstorage.GetObjectResponse, error) {
+	req.OpcSseCustomerAlgorithm = &c.key.Algorithm
+	req.OpcSseCustomerKey = &c.key.Key
+	req.OpcSseCustomerKeySha256 = &c.key.KeySHA256
+	return c.ObjectStorageAPI.GetObject(ctx, req)
+}
+
+// parseDateFlag parses a YYYY-MM-DD flag value as a UTC date
+func parseDateFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s date %q (expected YYYY-MM-DD): %w", name, value, err)
+	}
+	return date, nil
+}
+
+// simulateFailuresEnv must be 1 for -simulate-failure-rate to be accepted, so
+// a copied production command line cannot inject failures by accident
+const simulateFailuresEnv = "FOCUS_ALLOW_SIMULATED_FAILURES"
+
+// hiddenFlags are testing knobs left out of -help
+var hiddenFlags = map[string]bool{"simulate-failure-rate": true}
+
+// newHTTPClient builds the HTTP client used by the Object Storage client.
+// Requests go through proxyURL when set, otherwise through the proxy named by
+// HTTPS_PROXY/NO_PROXY. A connectTimeout bounds establishing the connection,
+// TLS handshake included; it does not limit the transfer itself.
+func newHTTPClient(proxyURL string, insecureSkipVerify bool, connectTimeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if connectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+	if insecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// parseEndpoint checks an -endpoint value, an http or https URL with a host
+// and no path, and returns it without a trailing slash
+func parseEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("%q must start with https:// or http://", endpoint)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%q has no host", endpoint)
+	}
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%q must not have a path, query or fragment", endpoint)
+	}
+	return strings.TrimSuffix(endpoint, "/"), nil
+}
+
+// preflightError explains a failed HeadBucket on the reports bucket. Object
+// Storage also answers 404 to callers that may not see the bucket, so a 404
+// names both causes.
+func preflightError(err error, namespace, bucketName string) string {
+	serviceErr, ok := common.IsServiceError(err)
+	switch {
+	case ok && serviceErr.GetHTTPStatusCode() == 404:
+		return fmt.Sprintf("No bucket %q in namespace %q, or no permission to see it: check -bucket (default: the tenancy OCID) and -namespace", bucketName, namespace)
+	case ok && (serviceErr.GetHTTPStatusCode() == 401 || serviceErr.GetHTTPStatusCode() == 403):
+		return fmt.Sprintf("Not authorized to read bucket %q: the user or principal needs a policy allowing it to read objects in the bucket", bucketName)
+	default:
+		return fmt.Sprintf("Cannot access bucket %q in namespace %q", bucketName, namespace)
+	}
+}
+
+// byteSizePattern splits a size such as "500MB" or "1.5 GiB" into number and unit
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([A-Za-z]*)$`)
+
+// byteUnits maps lower-cased size suffixes to their multiplier
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "tib": 1 << 40,
+}
+
+// parseByteSize parses a byte count with an optional decimal (KB, MB, GB, TB)
+// or binary (KiB, MiB, GiB, TiB) suffix; a bare number is a count of bytes
+func parseByteSize(value string) (int64, error) {
+	m := byteSizePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("invalid byte size %q", value)
+	}
+	factor, ok := byteUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", value, m[2])
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", value, err)
+	}
+	size := n * factor
+	// float64(math.MaxInt64) rounds up to 2^63, which int64 cannot hold
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid byte size %q: too large", value)
+	}
+	return int64(size), nil
+}
+
+// byteSize is a flag.Value accepting human-readable sizes via parseByteSize
+type byteSize int64
+
+func (b *byteSize) String() string { return strconv.FormatInt(int64(*b), 10) }
+
+func (b *byteSize) Set(value string) error {
+	n, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// printStats writes the run summary to stdout, the only output meant for scripts
+func printStats(stats focusreport.Stats, format string) {
+	if format == "json" {
+		json.NewEncoder(os.Stdout).Encode(stats)
+		return
+	}
+	line := fmt.Sprintf("listed=%d attempted=%d downloaded=%d skipped=%d failed=%d total_bytes=%d throughput_mbps=%.2f elapsed=%v",
+		stats.Listed, stats.Attempted, stats.Downloaded, stats.Skipped, stats.Failed, stats.TotalBytes, stats.Throughput, stats.Elapsed.Round(time.Millisecond))
+	if len(stats.FailuresByClass) > 0 {
+		classes := make([]string, 0, len(stats.FailuresByClass))
+		for class, n := range stats.FailuresByClass {
+			classes = append(classes, fmt.Sprintf("%s:%d", class, n))
+		}
+		sort.Strings(classes)
+		line += " failures=" + strings.Join(classes, ",")
+	}
+	fmt.Println(line)
+}
+
+// loadConfigFile applies a -config file to the flags of fs that were not given
+// on the command line. The file is a JSON or YAML object keyed by flag name
+// without the dash, e.g. {"workers": 8, "prefix": ["a/", "b/"]}; a list sets
+// a repeatable flag once per element. Unknown keys are an error.
+func loadConfigFile(filename string, fs *flag.FlagSet) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unknown format %q, expected .json, .yaml or .yml", ext)
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	var unknown []string
+	for key := range values {
+		keys = append(keys, key)
+		if fs.Lookup(key) == nil || key == "config" {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	sort.Strings(keys)
+
+	onCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+	for _, key := range keys {
+		if onCommandLine[key] {
+			continue
+		}
+		items, ok := values[key].([]interface{})
+		if !ok {
+			items = []interface{}{values[key]}
+		}
+		for _, item := range items {
+			var value string
+			switch v := item.(type) {
+			case string:
+				value = v
+			case bool, int, int64, uint64:
+				value = fmt.Sprint(v)
+			case float64:
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return fmt.Errorf("%s: unsupported value %v", key, item)
+			}
+			if err := fs.Set(key, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// newLogger builds the structured logger for the -log-level and -log-format
+// flags; logs always go to stderr
+func newLogger(level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level %q: must be debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid -log-format %q: must be text or json", format)
+}
+
+// Process exit codes
+const (
+	exitOK       = 0 // every listed report was downloaded or skipped
+	exitFatal    = 1 // setup error or interrupted run
+	exitFailures = 2 // at least one report failed
+	exitNoMatch  = 3 // no report matched the filters
+)
+
+// fatal logs msg with its attributes at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(exitFatal)
+}
+
+// exitCode maps the run summary to the process exit code. Without
+// failOnPartial, a run where some files failed but others were downloaded or
+// skipped still exits with exitOK. Without strict, reports deleted between
+// listing and download (not_found) do not count as failures.
+func exitCode(stats focusreport.Stats, failOnPartial, strict bool) int {
+	failed := stats.Failed
+	if !strict {
+		failed -= stats.FailuresByClass["not_found"]
+	}
+	switch {
+	case stats.Listed == 0:
+		return exitNoMatch
+	case failed == 0:
+		return exitOK
+	case !failOnPartial && stats.Downloaded+stats.Skipped > 0:
+		return exitOK
+	default:
+		return exitFailures
+	}
+}
+
+// tenancyOCIDPattern matches a tenancy OCID, ocid1.tenancy.<realm>.[region].<unique id>
+var tenancyOCIDPattern = regexp.MustCompile(`^ocid1\.tenancy\.[a-z0-9]+\.[a-z0-9-]*\.[a-z0-9]+$`)
+
+// newConfigProvider returns the OCI configuration provider for the -auth
+// method, along with a description of where it reads from for error messages.
+// The config file and profile only apply to the "config" method.
+func newConfigProvider(authMethod, configFile, profile string) (common.ConfigurationProvider, string, error) {
+	switch authMethod {
+	case "instance-principal":
+		provider, err := auth.InstancePrincipalConfigurationProvider()
+		return provider, "instance principal", err
+	case "resource-principal":
+		provider, err := auth.ResourcePrincipalConfigurationProvider()
+		return provider, "resource principal", err
+	case "security-token":
+		path, err := ociConfigPath(configFile)
+		if err != nil {
+			return nil, "", err
+		}
+		if profile == "" {
+			profile = "DEFAULT"
+		}
+		if err := checkSecurityToken(path, profile); err != nil {
+			return nil, "", err
+		}
+		provider, err := common.ConfigurationProviderForSessionTokenWithProfile(path, profile, "")
+		return provider, fmt.Sprintf("session token from OCI config file %s, profile %s", path, profile), err
+	case "config":
+	default:
+		return nil, "", fmt.Errorf("unknown -auth %q: must be config, security-token, instance-principal or resource-principal", authMethod)
+	}
+
+	if configFile == "" && profile == "" {
+		return common.DefaultConfigProvider(), "default OCI config (~/.oci/config, profile DEFAULT)", nil
+	}
+	if profile == "" {
+		profile = "DEFAULT"
+	}
+	source := fmt.Sprintf("OCI config file %s, profile %s", configFile, profile)
+	if configFile == "" {
+		source = fmt.Sprintf("OCI config file ~/.oci/config, profile %s", profile)
+	}
+	return common.CustomProfileConfigProvider(configFile, profile), source, nil
+}
+
+// ociConfigPath returns configFile, or ~/.oci/config when it is empty, with a
+// leading ~ expanded
+func ociConfigPath(configFile string) (string, error) {
+	if configFile == "" {
+		configFile = "~/.oci/config"
+	}
+	return expandHome(configFile)
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// profileValue returns the value of key in the [profile] section of the OCI
+// config file at path
+func profileValue(path, profile, key string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	return "", fmt.Errorf("no %s in profile %s of %s", key, profile, path)
+}
+
+// checkSecurityToken fails if the session token referenced by the profile's
+// security_token_file is missing or expired, so the run stops before any call
+func checkSecurityToken(configPath, profile string) error {
+	tokenFile, err := profileValue(configPath, profile, "security_token_file")
+	if err != nil {
+		return err
+	}
+	if tokenFile, err = expandHome(tokenFile); err != nil {
+		return err
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return fmt.Errorf("reading security token: %w", err)
+	}
+
+	// The token is a JWT; only its exp claim is needed
+	parts := strings.Split(strings.TrimSpace(string(token)), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("security token %s is not a JWT", tokenFile)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("decoding security token %s: %w", tokenFile, err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("decoding security token %s: %w", tokenFile, err)
+	}
+	if claims.Exp == 0 {
+		return nil
+	}
+	expiry := time.Unix(claims.Exp, 0)
+	if time.Now().After(expiry) {
+		return fmt.Errorf("security token expired at %s; run `oci session refresh --profile %s` or `oci session authenticate`",
+			expiry.Format(time.RFC3339), profile)
+	}
+	slog.Info("Using security token", "profile", profile, "expires", expiry.Format(time.RFC3339))
+	return nil
+}
+
+func main() {
+	workers := flag.String("workers", "4", "Number of concurrent download workers, or auto for one per CPU")
+	metadataWorkers := flag.Int("metadata-workers", 0, "Concurrent HeadObject calls for sizes, -date-source metadata and -object-list, separate from the download workers (default 4 per worker, up to 64)")
+	allowHighConcurrency := flag.Bool("allow-high-concurrency", false, fmt.Sprintf("Allow more than %d workers, up to %d", defaultMaxWorkers, hardMaxWorkers))
+	days := flag.Int("days", 7, "Number of past days to include in the report")
+	latest := flag.Int("latest", 0, "Only keep the N most recent reports by report date; without an explicit -days every date is considered (0 keeps all)")
+	downloadFolder := flag.String("download", "", "Folder to download reports (optional)")
+	reportFile := flag.String("report", "download_report.csv", "Download operation report file")
+	reportBucket := flag.String("report-bucket", "", "Also upload the operation report and the summary CSV to this bucket (empty keeps them on local disk only)")
+	reportObject := flag.String("report-object", "", "Object name of the uploaded operation report (default: the -report file name); the summary CSV is uploaded next to it")
+	noSizes := flag.Bool("no-sizes", false, "Never call HeadObject for the summary: faster on large buckets, but sizes missing from the listing are left empty")
+	forceHead := flag.Bool("force-head", false, "Resolve every object size with HeadObject instead of trusting listing sizes")
+	overwrite := flag.Bool("overwrite", false, "Re-download and atomically replace files that already exist locally")
+	skipExisting := flag.Bool("skip-existing", true, "Skip files that already exist locally; -skip-existing=false is the same as -overwrite")
+	autoRestore := flag.Bool("auto-restore", false, "Request a restore of Archive tier reports; they are downloaded by a later run")
+	restoreHours := flag.Int("restore-hours", 24, "Hours restored objects stay available, with -auto-restore")
+	resume := flag.Bool("resume", true, "Keep partial downloads on failure and resume them with Range requests")
+	metricsAddr := flag.String("metrics-addr", "", "Serve Prometheus metrics for downloads on this address, e.g. :9090 (empty disables)")
+	summarizeCostFlag := flag.Bool("summarize-cost", false, "Sum BilledCost by BillingCurrency across the downloaded files into -cost-summary-file")
+	costSummaryFile := flag.String("cost-summary-file", "cost_summary.csv", "Output of -summarize-cost")
+	validateFocus := flag.Bool("validate-focus", false, "Check each downloaded CSV header for the mandatory FOCUS columns")
+	inferExtension := flag.Bool("infer-extension", false, "Append .csv or .json from the object's Content-Type when its name has no extension")
+	deterministic := flag.Bool("deterministic", false, "Queue downloads and write operation report rows in object name order, for reproducible runs (with -workers 1, reproducible logs)")
+	sanitize := flag.String("sanitize", "auto", "Replace characters invalid in Windows file names (<>:\"/\\|?*, control characters, trailing dots and spaces) in local file names: auto (on Windows only), always or never")
+	sanitizeReplacement := flag.String("sanitize-replacement", "_", "Replacement for each invalid character with -sanitize")
+	noDatePrefix := flag.Bool("no-date-prefix", false, "Name files in the flat layout with the object's base name only, without the YYYYMMDD_ prefix (same as -filename-template {name})")
+	postDownloadCmd := flag.String("post-download-cmd", "", "Command run after each successful download, with the file path appended as last argument and FOCUS_* environment variables; not run through a shell")
+	postDownloadConcurrency := flag.Int("post-download-concurrency", 1, "Maximum -post-download-cmd commands running at once")
+	filenameTemplateFlag := flag.String("filename-template", focusreport.DefaultFilenameTemplate, "File name in the flat layout, with {name}, {date} (YYYYMMDD) and {date:layout} placeholders")
+	onCollision := flag.String("on-collision", "hash", "When several objects map to the same local file: hash (append a short hash of the object name), error (abort) or overwrite")
+	layout := flag.String("layout", "flat", "Download layout: flat (YYYYMMDD_name) or partitioned (YYYY/MM/DD/name)")
+	summarySort := flag.String("summary-sort", "date,desc", "Summary CSV order: date, size or name, optionally followed by ,asc or ,desc")
+	summaryFile := flag.String("summary-file", "oci_focus_reports.csv", "Summary CSV of all FOCUS reports (empty to skip)")
+	compressReports := flag.Bool("compress-reports", false, "Gzip the operation report and the summary CSVs, adding .gz to their names (independent of -decompress)")
+	summarySplit := flag.String("summary-split", "", "Also write one summary CSV per report month next to -summary-file, e.g. oci_focus_reports_2024-03.csv: month (empty disables)")
+	summarySplitOnly := flag.Bool("summary-split-only", false, "With -summary-split, write only the per-month summary CSVs and not the combined one")
+	reportFormat := flag.String("report-format", "csv", "Download operation report format: csv or json")
+	maxAge := flag.Duration("max-age", 0, "Only include reports dated at most this long ago, e.g. 36h (overrides -days)")
+	minAge := flag.Duration("min-age", 0, "Only include reports dated at least this long ago, e.g. 12h")
+	fromDate := flag.String("from", "", "Start date YYYY-MM-DD, inclusive (overrides -days)")
+	toDate := flag.String("to", "", "End date YYYY-MM-DD, inclusive (overrides -days)")
+	maxRetries := flag.Int("max-retries", 3, "Maximum retries for transient OCI errors")
+	retryBaseDelay := flag.Duration("retry-base-delay", 500*time.Millisecond, "Initial backoff delay between retries")
+	retryMaxDelay := flag.Duration("retry-max-delay", 30*time.Second, "Maximum backoff delay between retries")
+	proxyURL := flag.String("proxy-url", "", "HTTP proxy for all OCI requests, e.g. http://proxy.example.com:3128 (default from HTTPS_PROXY)")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Do not verify TLS certificates of OCI endpoints (internal CAs only; insecure)")
+	connectTimeout := flag.Duration("connect-timeout", 0, "Timeout to establish each connection, TLS handshake included (0 keeps the default)")
+	requestTimeout := flag.Duration("request-timeout", 0, "Timeout per OCI request; for downloads, the longest stall without data (0 disables)")
+	authMethod := flag.String("auth", "config", "Authentication: config (OCI config file), security-token (oci session authenticate), instance-principal or resource-principal")
+	configFile := flag.String("config-file", "", "OCI config file path (default ~/.oci/config)")
+	profile := flag.String("profile", "", "OCI config profile (default DEFAULT)")
+	sseKeyFile := flag.String("sse-c-key-file", "", "File with the base64 AES-256 key for buckets encrypted with SSE-C")
+	bucketFlag := flag.String("bucket", "", "Bucket containing the FOCUS reports (default tenancy OCID)")
+	tenancyFlag := flag.String("tenancy", "", "Tenancy OCID whose cost reports are read, for cross-tenancy access; authentication still uses the current identity (default the authenticated tenancy)")
+	verifyChecksums := flag.Bool("verify-checksums", true, "Verify downloaded content against the object's Content-MD5")
+	decompress := flag.Bool("decompress", false, "Gunzip .gz objects on download and drop the .gz suffix")
+	checkpointInterval := flag.Duration("checkpoint-interval", 0, "Rewrite the operation report with the results so far at this interval during downloads, e.g. 1m (0 disables)")
+	progressInterval := flag.Duration("progress-interval", 5*time.Second, "Interval between aggregated progress logs (0 disables)")
+	quiet := flag.Bool("quiet", false, "Suppress progress and per-file download logs")
+	regionFlag := flag.String("region", "", "OCI region to use instead of the config file's, e.g. us-ashburn-1")
+	endpointFlag := flag.String("endpoint", "", "Object Storage endpoint URL used instead of the region's, e.g. for Dedicated Region Cloud@Customer or a local mock (http://localhost:8080)")
+	regionsFlag := flag.String("regions", "", "Comma-separated regions to list and download from in turn, e.g. us-ashburn-1,eu-frankfurt-1, merged into one report and summary CSV; downloads go to a subfolder per region")
+	listPrefixesFlag := flag.Bool("list-prefixes", false, "Print the prefix hierarchy of the bucket (under -prefix) and exit without downloading")
+	prefixDepth := flag.Int("prefix-depth", 4, "Number of prefix levels printed by -list-prefixes")
+	dateSource := flag.String("date-source", "path", "Where report dates come from: path (YYYY/MM/DD in the name) or metadata (HeadObject, falling back to the path)")
+	dateMetaKey := flag.String("date-metadata-key", "report-date", "Object metadata key holding the report date for -date-source metadata")
+	objectList := flag.String("object-list", "", "File of object names to download instead of listing the bucket: one per line, or a .csv with an object_path or object_name column")
+	objectsJSON := flag.String("objects-json", "", "Write the matched objects (name, size, date, etag, tier) as JSON to this file, or - for stdout, before any download")
+	resumeReport := flag.String("resume-report", "", "Retry only the Failed, Timeout and Needs restore rows of this earlier operation report instead of listing the bucket, and write the report with those rows updated")
+	objectListColumn := flag.String("object-list-column", "", "Column holding the object names when -object-list is a CSV (default object_path, then object_name)")
+	var prefixes stringList
+	flag.Var(&prefixes, "prefix", "Only list objects whose name starts with this prefix, e.g. a compartment's export folder; repeatable, prefixes are listed concurrently")
+	namePattern := flag.String("name-pattern", focusreport.DefaultNamePattern, "Regular expression object names must match")
+	var include, exclude globList
+	flag.Var(&include, "include", "Only keep FOCUS objects matching this glob, e.g. '*compute*'; repeatable")
+	flag.Var(&exclude, "exclude", "Drop objects matching this glob, e.g. '*_manifest.json'; repeatable, wins over -include")
+	sinceLastRun := flag.Bool("since-last-run", false, "Only list reports dated after the latest one recorded in -state-file (-days on the first run)")
+	skipPreflight := flag.Bool("skip-preflight", false, "Do not check with HeadBucket that the bucket exists and is readable before listing")
+	refreshIdentity := flag.Bool("refresh-identity", false, "Resolve the namespace and bucket again instead of using the ones cached in -state-file")
+	etagSidecars := flag.Bool("etag-sidecars", false, "Write each file's ETag to <file>.etag and skip existing files only when the remote ETag still matches")
+	stateFile := flag.String("state-file", "", "JSON manifest of downloaded ETags; files are re-downloaded when the remote ETag changes")
+	var minSize, maxSize, maxTotalBytes, maxBandwidth byteSize
+	flag.Var(&minSize, "min-size", "Skip objects smaller than this size, e.g. 1, 10MB or 1GiB (default no limit)")
+	flag.Var(&maxSize, "max-size", "Skip objects larger than this size, e.g. 2GB (default no limit)")
+	flag.Var(&maxBandwidth, "max-bandwidth", "Cap the download rate of all workers together, in bytes per second, e.g. 20MB (default unlimited)")
+	flag.Var(&maxTotalBytes, "max-total-bytes", "Stop queuing downloads once this many bytes are planned, e.g. 50GB (default unlimited)")
+	maxObjects := flag.Int("max-objects", 0, "Stop listing after this many matching reports, e.g. for a smoke test against a large bucket (0 lists all)")
+	pageSize := flag.Int("page-size", focusreport.MaxPageSize, fmt.Sprintf("Objects per ListObjects page, 1 to %d", focusreport.MaxPageSize))
+	dryRun := flag.Bool("dry-run", false, "List what would be downloaded, with sizes, without downloading")
+	compareOnly := flag.Bool("compare-only", false, "Compare the bucket with the -download folder and write -compare-file, without downloading")
+	compareFile := flag.String("compare-file", "comparison_report.csv", "Output of -compare-only")
+	verifyOnly := flag.Bool("verify-only", false, "Check the files in the -download folder against the bucket (size, ETag, MD5) and write -verify-file, without downloading")
+	verifyFile := flag.String("verify-file", "verify_report.csv", "Output of -verify-only")
+	scanLocalFlag := flag.Bool("scan-local", false, "Check that the files in the -download folder are non-empty and write -scan-file, without contacting OCI")
+	scanGzip := flag.Bool("scan-gzip", false, "With -scan-local, also decompress .gz files to check they are intact")
+	scanFile := flag.String("scan-file", "scan_report.csv", "Output of -scan-local")
+	namespaceFlag := flag.String("namespace", "bling", "Object Storage namespace; empty resolves the tenancy's own namespace")
+	strict := flag.Bool("strict", false, "Count reports deleted between listing and download (status Not found) as failures in the exit code")
+	failOnPartial := flag.Bool("fail-on-partial", true, "Exit with code 2 if any file failed, even when others succeeded")
+	eventsFormat := flag.String("events", "", "Stream download events (listed, started, progress, completed, failed) on stdout: jsonl (empty disables)")
+	stdoutFormat := flag.String("stdout-format", "text", "Format of the final run summary on stdout: text or json")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	simulateFailureRate := flag.Float64("simulate-failure-rate", 0, "Testing only: fail this fraction of GetObject attempts with a retryable error; requires "+simulateFailuresEnv+"=1")
+	configPath := flag.String("config", "", "JSON or YAML file of flag values keyed by flag name, e.g. {\"workers\": 8}; flags on the command line take precedence")
+	flag.Usage = func() {
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+		flag.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		visible.PrintDefaults()
+	}
+	flag.Parse()
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -config file %s: %v\n", *configPath, err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitFatal)
+	}
+	slog.SetDefault(logger)
+
+	config := focusreport.Config{
+		Days:           *days,
+		DownloadFolder: *downloadFolder,
+		ReportFile:     *reportFile,
+		Retry: focusreport.RetryConfig{
+			MaxRetries:     *maxRetries,
+			BaseDelay:      *retryBaseDelay,
+			MaxDelay:       *retryMaxDelay,
+			RequestTimeout: *requestTimeout,
+		},
+		AuthMethod:     *authMethod,
+		OCIConfigFile:  *configFile,
+		OCIProfile:     *profile,
+		Namespace:      *namespaceFlag,
+		BucketName:     *bucketFlag,
+		VerifyChecksum: *verifyChecksums,
+		Decompress:     *decompress,
+		ReportFormat:   *reportFormat,
+		ProgressEvery:  *progressInterval,
+		Checkpoint:     *checkpointInterval,
+		Quiet:          *quiet,
+		DryRun:         *dryRun,
+		Region:         *regionFlag,
+		StateFile:      *stateFile,
+		Include:        include,
+		Exclude:        exclude,
+		ETagSidecars:   *etagSidecars,
+		MinSize:        int64(minSize),
+		MaxSize:        int64(maxSize),
+		MaxTotalBytes:  int64(maxTotalBytes),
+		MaxObjects:     *maxObjects,
+		PageSize:       *pageSize,
+		MaxBandwidth:   int64(maxBandwidth),
+		SummaryFile:    *summaryFile,
+		ForceHead:      *forceHead,
+		NoSizes:        *noSizes,
+		StdoutFormat:   *stdoutFormat,
+		Layout:         *layout,
+		Overwrite:      *overwrite || !*skipExisting,
+		ValidateFocus:  *validateFocus,
+		InferExtension: *inferExtension,
+		Deterministic:  *deterministic,
+		PostHook:       strings.Fields(*postDownloadCmd),
+		HookWorkers:    *postDownloadConcurrency,
+		FailureRate:    *simulateFailureRate,
+		Resume:         *resume,
+		Prefixes:       prefixes,
+		AutoRestore:    *autoRestore,
+		RestoreHours:   *restoreHours,
+		SinceLastRun:   *sinceLastRun,
+		Latest:         *latest,
+		OnCollision:    *onCollision,
+		CompareOnly:    *compareOnly,
+		CompareFile:    *compareFile,
+		VerifyOnly:     *verifyOnly,
+		VerifyFile:     *verifyFile,
+		MetricsAddr:    *metricsAddr,
+		SummarySplit:   *summarySplit,
+		ObjectList:     *objectList,
+		ObjectColumn:   *objectListColumn,
+		ResumeReport:   *resumeReport,
+		ObjectsJSON:    *objectsJSON,
+		SplitOnly:      *summarySplitOnly,
+		Events:         *eventsFormat,
+	}
+	if *summarizeCostFlag {
+		if config.DownloadFolder == "" {
+			fatal("-summarize-cost requires -download")
+		}
+		config.CostSummary = *costSummaryFile
+	}
+	if *overwrite {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "skip-existing" && *skipExisting {
+				fatal("-overwrite and -skip-existing are mutually exclusive")
+			}
+		})
+	}
+	switch *dateSource {
+	case "path":
+	case "metadata":
+		config.Dates = focusreport.NewDateIndex(*dateMetaKey)
+	default:
+		fatal("Invalid -date-source: must be path or metadata", "value", *dateSource)
+	}
+	if config.RestoreHours < 1 || config.RestoreHours > 240 {
+		fatal("Invalid -restore-hours: must be between 1 and 240", "value", config.RestoreHours)
+	}
+	if config.NoSizes && config.ForceHead {
+		fatal("-no-sizes and -force-head are mutually exclusive")
+	}
+	switch {
+	case *sanitize == "always" || (*sanitize == "auto" && runtime.GOOS == "windows"):
+		if *sanitizeReplacement == "" || focusreport.SanitizeFileName(*sanitizeReplacement, "_") != *sanitizeReplacement {
+			fatal("Invalid -sanitize-replacement: must be non-empty and valid in file names", "value", *sanitizeReplacement)
+		}
+		config.Sanitize = *sanitizeReplacement
+	case *sanitize != "auto" && *sanitize != "never":
+		fatal("Invalid -sanitize: must be auto, always or never", "value", *sanitize)
+	}
+	if *compressReports {
+		if !strings.HasSuffix(config.ReportFile, ".gz") {
+			config.ReportFile += ".gz"
+		}
+		if config.SummaryFile != "" && !strings.HasSuffix(config.SummaryFile, ".gz") {
+			config.SummaryFile += ".gz"
+		}
+	}
+	if config.ResumeReport != "" && config.ObjectList != "" {
+		fatal("-resume-report and -object-list are mutually exclusive")
+	}
+	endpoint := ""
+	if *endpointFlag != "" {
+		if endpoint, err = parseEndpoint(*endpointFlag); err != nil {
+			fatal("Invalid -endpoint", "error", err)
+		}
+		if *regionsFlag != "" {
+			fatal("-endpoint and -regions are mutually exclusive; an endpoint serves a single region")
+		}
+	}
+	var regions []string
+	if *regionsFlag != "" {
+		seen := make(map[string]bool)
+		for _, name := range strings.Split(*regionsFlag, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			region := common.StringToRegion(name)
+			if _, err := region.RealmID(); err != nil {
+				fatal("Invalid -regions", "region", name, "error", err)
+			}
+			seen[name] = true
+			regions = append(regions, string(region))
+		}
+		switch {
+		case config.Region != "":
+			fatal("-region and -regions are mutually exclusive")
+		case config.CompareOnly || *verifyOnly || config.ObjectList != "" || config.ResumeReport != "":
+			fatal("-regions cannot be combined with -compare-only, -verify-only, -object-list or -resume-report")
+		case config.MetricsAddr != "":
+			fatal("-regions cannot be combined with -metrics-addr, every region would serve on the same address")
+		case config.ObjectsJSON == "-":
+			fatal("-objects-json - is not supported with -regions; give a file, written once per region")
+		}
+	}
+	if config.HookWorkers < 1 {
+		fatal("Invalid -post-download-concurrency: must be at least 1", "value", config.HookWorkers)
+	}
+	if config.FailureRate != 0 {
+		if os.Getenv(simulateFailuresEnv) != "1" {
+			fatal("-simulate-failure-rate is a testing knob and requires " + simulateFailuresEnv + "=1 in the environment")
+		}
+		if config.FailureRate < 0 || config.FailureRate > 1 {
+			fatal("Invalid -simulate-failure-rate: must be between 0 and 1", "value", config.FailureRate)
+		}
+		slog.Warn("Injecting simulated download failures; do not use outside of testing", "rate", config.FailureRate)
+	}
+	if *noDatePrefix {
+		if *filenameTemplateFlag != focusreport.DefaultFilenameTemplate {
+			fatal("-no-date-prefix and -filename-template are mutually exclusive")
+		}
+		*filenameTemplateFlag = "{name}"
+	}
+	if config.NameTemplate, err = focusreport.ParseFilenameTemplate(*filenameTemplateFlag); err != nil {
+		fatal("Invalid -filename-template", "error", err)
+	}
+	if !config.NameTemplate.HasDate() && config.Layout == "flat" && !*noDatePrefix {
+		slog.Warn("-filename-template has no {date}; reports with the same name on different days will share a file name")
+	}
+	if config.Layout == "partitioned" && *filenameTemplateFlag != focusreport.DefaultFilenameTemplate {
+		slog.Warn("-filename-template only applies to -layout flat")
+	}
+	if config.Layout != "flat" && config.Layout != "partitioned" {
+		fatal("Invalid -layout: must be flat or partitioned", "value", config.Layout)
+	}
+	if *compareOnly {
+		if config.DownloadFolder == "" {
+			fatal("-compare-only requires -download")
+		}
+		if config.DryRun {
+			fatal("-compare-only and -dry-run are mutually exclusive")
+		}
+	}
+	if *verifyOnly {
+		if config.DownloadFolder == "" {
+			fatal("-verify-only requires -download")
+		}
+		if config.DryRun || config.CompareOnly {
+			fatal("-verify-only is mutually exclusive with -dry-run and -compare-only")
+		}
+	}
+	if *scanLocalFlag {
+		if config.DownloadFolder == "" {
+			fatal("-scan-local requires -download")
+		}
+		if config.DryRun || config.CompareOnly || config.VerifyOnly {
+			fatal("-scan-local is mutually exclusive with -dry-run, -compare-only and -verify-only")
+		}
+	} else if *scanGzip {
+		slog.Warn("-scan-gzip only applies to -scan-local")
+	}
+	if config.ObjectsJSON == "-" && config.Events == "jsonl" {
+		fatal("-objects-json - and -events jsonl both write to stdout; write the objects to a file instead")
+	}
+	if *tenancyFlag != "" && !tenancyOCIDPattern.MatchString(*tenancyFlag) {
+		fatal("Invalid -tenancy: expected a tenancy OCID such as ocid1.tenancy.oc1..<unique id>", "value", *tenancyFlag)
+	}
+	if *onCollision != "hash" && *onCollision != "error" && *onCollision != "overwrite" {
+		fatal("Invalid -on-collision: must be hash, error or overwrite", "value", *onCollision)
+	}
+	if config.SummaryLess, err = focusreport.ParseSummarySort(*summarySort); err != nil {
+		fatal("Invalid -summary-sort", "error", err)
+	}
+	if config.SummarySplit != "" && config.SummarySplit != "month" {
+		fatal("Invalid -summary-split: must be month or empty", "value", config.SummarySplit)
+	}
+	if config.SplitOnly && config.SummarySplit == "" {
+		fatal("-summary-split-only requires -summary-split")
+	}
+	if len(config.Prefixes) == 1 {
+		config.Prefix = config.Prefixes[0]
+	}
+	if config.Events != "" && config.Events != "jsonl" {
+		fatal("Invalid -events: must be jsonl or empty", "value", config.Events)
+	}
+	if config.StdoutFormat != "text" && config.StdoutFormat != "json" {
+		fatal("Invalid -stdout-format: must be text or json", "value", config.StdoutFormat)
+	}
+	if config.NamePattern, err = regexp.Compile(*namePattern); err != nil {
+		fatal("Invalid -name-pattern", "error", err)
+	}
+	if config.ReportFormat != "csv" && config.ReportFormat != "json" {
+		fatal("Invalid -report-format: must be csv or json", "value", config.ReportFormat)
+	}
+	if config.Retry.MaxRetries < 0 {
+		config.Retry.MaxRetries = 0
+	}
+
+	// Resolve the date window; an explicit -from/-to range wins over -days,
+	// and -max-age replaces -days as the lower bound
+	if config.FromDate, err = parseDateFlag("from", *fromDate); err != nil {
+		fatal("Invalid date", "error", err)
+	}
+	if config.ToDate, err = parseDateFlag("to", *toDate); err != nil {
+		fatal("Invalid date", "error", err)
+	}
+	if *sinceLastRun {
+		if config.StateFile == "" {
+			fatal("-since-last-run requires -state-file")
+		}
+		if !config.FromDate.IsZero() {
+			fatal("-since-last-run and -from are mutually exclusive")
+		}
+	}
+	explicitRange := !config.FromDate.IsZero() || !config.ToDate.IsZero()
+	daysSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "days" {
+			daysSet = true
+		}
+	})
+	if config.MaxObjects < 0 {
+		fatal("Invalid -max-objects: must not be negative", "value", config.MaxObjects)
+	}
+	if config.PageSize < 1 || config.PageSize > focusreport.MaxPageSize {
+		fatal("Invalid -page-size: must be between 1 and 1000", "value", config.PageSize)
+	}
+	if config.Latest < 0 {
+		fatal("Invalid -latest: must not be negative", "value", config.Latest)
+	}
+	if *maxAge < 0 || *minAge < 0 {
+		fatal("Invalid age: -max-age and -min-age must not be negative")
+	}
+	if explicitRange && (*maxAge > 0 || *minAge > 0) {
+		fatal("-max-age/-min-age and -from/-to are mutually exclusive")
+	}
+	if *maxAge > 0 && *minAge > *maxAge {
+		fatal("Invalid age range: -min-age is larger than -max-age", "min_age", *minAge, "max_age", *maxAge)
+	}
+	if explicitRange {
+		if !config.FromDate.IsZero() && !config.ToDate.IsZero() && config.ToDate.Before(config.FromDate) {
+			fatal("Invalid date range: -from is after -to", "from", *fromDate, "to", *toDate)
+		}
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "days" {
+				slog.Warn("-from/-to given, ignoring -days", "days", config.Days)
+			}
+		})
+	} else {
+		// Report dates are UTC midnights, so ages are measured from UTC now
+		now := time.Now().UTC()
+		if *maxAge > 0 {
+			config.FromDate = now.Add(-*maxAge)
+			flag.Visit(func(f *flag.Flag) {
+				if f.Name == "days" {
+					slog.Warn("-max-age given, ignoring -days", "days", config.Days)
+				}
+			})
+		} else if config.Latest == 0 || daysSet {
+			// -latest alone picks from every date, not the default -days window
+			config.FromDate = focusreport.DaysCutoff(now, config.Days)
+		}
+		if *minAge > 0 {
+			config.ToDate = now.Add(-*minAge)
+		}
+	}
+
+	// Validate workers count
+	autoWorkers := *workers == "auto"
+	if autoWorkers {
+		// One worker per CPU suits many small files; bandwidth-bound
+		// downloads of large files need an explicit count
+		config.MaxWorkers = runtime.NumCPU()
+		ceiling := defaultMaxWorkers
+		if *allowHighConcurrency {
+			ceiling = hardMaxWorkers
+		}
+		if config.MaxWorkers > ceiling {
+			config.MaxWorkers = ceiling
+		}
+	} else if config.MaxWorkers, err = strconv.Atoi(*workers); err != nil {
+		fatal("Invalid -workers: must be a number or auto", "value", *workers)
+	}
+	if config.MaxWorkers < 1 {
+		config.MaxWorkers = 1
+	}
+	if !*allowHighConcurrency && config.MaxWorkers > defaultMaxWorkers {
+		slog.Warn("Limiting workers for safety; use -allow-high-concurrency to raise the limit",
+			"requested", config.MaxWorkers, "limit", defaultMaxWorkers, "hard_limit", hardMaxWorkers)
+		config.MaxWorkers = defaultMaxWorkers
+	}
+	if config.MaxWorkers > hardMaxWorkers {
+		slog.Warn("Limiting workers to the hard limit", "requested", config.MaxWorkers, "limit", hardMaxWorkers)
+		config.MaxWorkers = hardMaxWorkers
+	}
+	slog.Info("Effective worker count", "workers", config.MaxWorkers, "auto", autoWorkers)
+	switch {
+	case *metadataWorkers < 0:
+		fatal("Invalid -metadata-workers: must not be negative", "value", *metadataWorkers)
+	case *metadataWorkers > 0:
+		config.MetaWorkers = min(*metadataWorkers, hardMaxWorkers)
+	default:
+		config.MetaWorkers = max(config.MaxWorkers, min(config.MaxWorkers*metadataWorkersPerWorker, defaultMetadataWorkers))
+	}
+
+	// The local scan needs no credentials, so it runs before authentication
+	if *scanLocalFlag {
+		results, err := focusreport.ScanLocal(config.DownloadFolder, *scanGzip, config.MaxWorkers)
+		if err != nil {
+			fatal("Failed to scan the download folder", "path", config.DownloadFolder, "error", err)
+		}
+		if err := focusreport.WriteScanCSV(results, *scanFile); err != nil {
+			fatal("Failed to write scan report", "path", *scanFile, "error", err)
+		}
+		counts := make(map[string]int)
+		undated := 0
+		for _, r := range results {
+			counts[r.Status]++
+			if r.ReportDate == "" {
+				undated++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Scan written to: %s (%d OK, %d empty, %d invalid gzip, %d without a date)\n",
+			*scanFile, counts["OK"], counts["Empty"], counts["InvalidGzip"], undated)
+		return
+	}
+
+	var sseKey *sseCustomerKey
+	if *sseKeyFile != "" {
+		if sseKey, err = loadSSECustomerKey(*sseKeyFile); err != nil {
+			fatal("Invalid -sse-c-key-file", "path", *sseKeyFile, "error", err)
+		}
+	}
+
+	if config.AuthMethod != "config" && config.AuthMethod != "security-token" && (config.OCIConfigFile != "" || config.OCIProfile != "") {
+		slog.Warn("-config-file and -profile are ignored with -auth " + config.AuthMethod)
+	}
+	provider, source, err := newConfigProvider(config.AuthMethod, config.OCIConfigFile, config.OCIProfile)
+	if err != nil {
+		fatal("Failed to initialize authentication", "auth", config.AuthMethod, "error", err)
+	}
+
+	client, err := objectstorage.NewObjectStorageClientWithConfigurationProvider(provider)
+	if err != nil {
+		fatal("Error creating Object Storage client", "error", err)
+	}
+
+	// Route OCI traffic through a proxy or custom TLS settings when asked to;
+	// otherwise the SDK's own HTTP client is kept
+	if *proxyURL != "" || *insecureSkipVerify || *connectTimeout > 0 || os.Getenv("HTTPS_PROXY") != "" || os.Getenv("https_proxy") != "" {
+		httpClient, err := newHTTPClient(*proxyURL, *insecureSkipVerify, *connectTimeout)
+		if err != nil {
+			fatal("Invalid -proxy-url", "error", err)
+		}
+		client.HTTPClient = httpClient
+		if *insecureSkipVerify {
+			slog.Warn("TLS certificate verification is disabled with -insecure-skip-verify; use it only for internal CAs on trusted networks")
+		}
+	}
+
+	// Override the config file's region if requested
+	if config.Region != "" {
+		region := common.StringToRegion(config.Region)
+		if _, err := region.RealmID(); err != nil {
+			fatal("Invalid -region", "region", config.Region, "error", err)
+		}
+		client.SetRegion(string(region))
+		slog.Info("Using region", "region", region, "source", "-region")
+	} else if region, err := provider.Region(); err == nil {
+		slog.Info("Using region", "region", region, "source", "config")
+		config.Region = region
+	}
+
+	// A custom endpoint replaces the one derived from the region
+	if endpoint != "" {
+		client.Host = endpoint
+		slog.Info("Using Object Storage endpoint", "endpoint", endpoint)
+		if strings.HasPrefix(endpoint, "http://") {
+			slog.Warn("-endpoint uses plain HTTP; requests and credentials are not encrypted")
+		}
+	}
+
+	var api focusreport.ObjectStorageAPI = client
+	if sseKey != nil {
+		api = sseCustomerClient{ObjectStorageAPI: client, key: sseKey}
+	}
+
+	// One client per -regions entry, sharing the HTTP settings; the home
+	// region client still resolves the namespace and checks the bucket
+	var regionClients []focusreport.RegionClient
+	for _, name := range regions {
+		regional := client
+		regional.SetRegion(name)
+		var regionalAPI focusreport.ObjectStorageAPI = regional
+		if sseKey != nil {
+			regionalAPI = sseCustomerClient{ObjectStorageAPI: regional, key: sseKey}
+		}
+		regionClients = append(regionClients, focusreport.RegionClient{Region: name, Client: regionalAPI})
+	}
+	if len(regionClients) > 0 {
+		slog.Info("Listing several regions", "regions", strings.Join(regions, ","))
+	}
+
+	// Cancel in-flight work on Ctrl-C / SIGTERM; partial files are cleaned up,
+	// or kept for -resume, by fetchObject and the collected results are still reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var manifest *focusreport.Manifest
+	if config.StateFile != "" {
+		if manifest, err = focusreport.LoadManifest(config.StateFile); err != nil {
+			fatal("Failed to load state file", "path", config.StateFile, "error", err)
+		}
+	}
+	// With a state file, reuse the namespace, bucket and tenancy resolved by an
+	// earlier run for this profile, as long as the bucket is still there. Only
+	// the values not given on the command line or in -config come from the
+	// cache, so the -namespace default does not disable it.
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	namespace := config.Namespace
+	bucketName := config.BucketName
+	profileKey := config.OCIProfile
+	if config.AuthMethod == "instance-principal" || config.AuthMethod == "resource-principal" {
+		profileKey = config.AuthMethod
+	} else if profileKey == "" {
+		profileKey = "DEFAULT"
+	}
+	// The cached identity is per profile, not per -tenancy target. It is
+	// recorded only when neither value was given, so it never holds an explicit one.
+	useCache := manifest != nil && !explicit["tenancy"] && !(explicit["namespace"] && explicit["bucket"])
+	recordIdentity := useCache && !explicit["namespace"] && !explicit["bucket"]
+	var tenancyID string
+	bucketChecked := false
+	if useCache && !*refreshIdentity {
+		if cached, ok := manifest.Identity(profileKey); ok {
+			cachedNamespace, cachedBucket := namespace, bucketName
+			if !explicit["namespace"] {
+				cachedNamespace = cached.Namespace
+			}
+			if !explicit["bucket"] {
+				cachedBucket = cached.BucketName
+			}
+			if err := focusreport.HeadBucket(ctx, client, config.Retry, cachedNamespace, cachedBucket); err != nil {
+				slog.Warn("Cached namespace and bucket failed validation, resolving again",
+					"namespace", cachedNamespace, "bucket", cachedBucket, "error", err)
+			} else {
+				namespace, bucketName, tenancyID = cachedNamespace, cachedBucket, cached.TenancyID
+				recordIdentity = false
+				bucketChecked = true
+				slog.Info("Using cached namespace and bucket", "profile", profileKey, "resolved_at", cached.ResolvedAt)
+			}
+		}
+	}
+
+	// Validate the provider before doing any work, unless the cached bucket
+	// check already did; entries cached before the tenancy was kept have none
+	if tenancyID == "" {
+		if tenancyID, err = provider.TenancyOCID(); err != nil {
+			fatal("Failed to read tenancy OCID", "source", source, "error", err)
+		}
+	}
+	// Cross-tenancy reads keep the current identity but target the other
+	// tenancy's bucket; the policies of both tenancies must allow it
+	if *tenancyFlag != "" {
+		slog.Info("Reading the reports of another tenancy", "tenancy", *tenancyFlag, "authenticated_tenancy", tenancyID)
+		tenancyID = *tenancyFlag
+	}
+
+	if namespace == "" {
+		namespace, err = focusreport.ResolveNamespace(ctx, client, config.Retry)
+		if err != nil {
+			fatal("Failed to resolve Object Storage namespace", "error", err)
+		}
+	}
+	slog.Info("Using Object Storage namespace", "namespace", namespace)
+	if bucketName == "" {
+		bucketName = tenancyID
+	}
+	if recordIdentity {
+		entry := focusreport.IdentityEntry{Namespace: namespace, BucketName: bucketName, TenancyID: tenancyID, ResolvedAt: time.Now().UTC()}
+		if err := manifest.RecordIdentity(profileKey, entry); err != nil {
+			slog.Warn("Could not update state file", "path", config.StateFile, "error", err)
+		}
+	}
+
+	// Fail early with a clear message if the bucket is wrong or unreadable
+	if !*skipPreflight && !bucketChecked {
+		if err := focusreport.HeadBucket(ctx, client, config.Retry, namespace, bucketName); err != nil {
+			fatal(preflightError(err, namespace, bucketName), "error", err)
+		}
+	}
+
+	if *reportBucket != "" {
+		if err := focusreport.HeadBucket(ctx, client, config.Retry, namespace, *reportBucket); err != nil {
+			fatal("Cannot access -report-bucket", "bucket", *reportBucket, "error", err)
+		}
+	}
+
+	// Explore the bucket layout only
+	if *listPrefixesFlag {
+		roots := config.Prefixes
+		if len(roots) == 0 {
+			roots = []string{""}
+		}
+		level := 0
+		if len(roots) > 1 {
+			level = 1
+		}
+		for _, root := range roots {
+			if level > 0 {
+				fmt.Println(root)
+			}
+			if err := focusreport.PrintPrefixTree(ctx, api, config.Retry, namespace, bucketName, root, *prefixDepth, level); err != nil {
+				fatal("Failed to list prefixes", "prefix", root, "error", err)
+			}
+		}
+		return
+	}
+
+	// List, download and write the reports
+	config.Namespace, config.BucketName, config.TenancyID = namespace, bucketName, tenancyID
+	var stats focusreport.Stats
+	var results []focusreport.OperationResult
+	if len(regionClients) > 0 {
+		stats, results, err = focusreport.RunRegions(ctx, config, regionClients)
+	} else {
+		stats, results, err = focusreport.Run(ctx, config, api)
+	}
+	// Failed files are in the report and decide the exit code below
+	var failures *focusreport.DownloadErrors
+	if err != nil && !errors.As(err, &failures) {
+		if ctx.Err() != nil {
+			stop()
+			printStats(stats, config.StdoutFormat)
+			fatal("Interrupted", "files_processed", len(results))
+		}
+		fatal("Run failed", "error", err)
+	}
+	if config.CompareOnly || config.VerifyOnly {
+		return
+	}
+
+	// Keep a copy of the reports beyond the local disk
+	if *reportBucket != "" {
+		var uploads [][2]string // local file, object name
+		base := *reportObject
+		if base == "" {
+			base = filepath.Base(config.ReportFile)
+		}
+		if config.DryRun || config.DownloadFolder != "" {
+			uploads = append(uploads, [2]string{config.ReportFile, base})
+		}
+		if config.SummaryFile != "" && !config.SplitOnly {
+			uploads = append(uploads, [2]string{config.SummaryFile, path.Join(path.Dir(base), filepath.Base(config.SummaryFile))})
+		}
+		for _, upload := range uploads {
+			filename, objectName := upload[0], upload[1]
+			if err := focusreport.UploadFile(ctx, client, config.Retry, namespace, *reportBucket, objectName, filename); err != nil {
+				fatal("Failed to upload report", "path", filename, "bucket", *reportBucket, "object", objectName, "error", err)
+			}
+			fmt.Fprintf(os.Stderr, "Uploaded %s to %s/%s\n", filename, *reportBucket, objectName)
+		}
+	}
+	printStats(stats, config.StdoutFormat)
+	if code := exitCode(stats, *failOnPartial, *strict); code != exitOK {
+		stop()
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"10", 10, true},
+		{"0", 0, true},
+		{" 500MB ", 500e6, true},
+		{"500mb", 500e6, true},
+		{"1.5KB", 1500, true},
+		{"2GiB", 2 << 30, true},
+		{"1 MiB", 1 << 20, true},
+		{"10k", 10e3, true},
+		{"1TiB", 1 << 40, true},
+		{"", 0, false},
+		{"10xb", 0, false},
+		{"-5", 0, false},
+		{"-5MB", 0, false},
+		{"MB", 0, false},
+		{"1e3", 0, false},
+		{"1.5.2KB", 0, false},
+		{"10000000TB", 0, false},
+		{"9223372036854775808", 0, false},
+	} {
+		got, err := parseByteSize(tc.value)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d, ok %v", tc.value, got, err, tc.want, tc.ok)
+		}
+	}
+}